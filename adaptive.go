@@ -0,0 +1,171 @@
+package cutout
+
+import (
+	"sync"
+	"time"
+)
+
+// AdaptiveLimiter limits the calls in flight through the circuit breakers it is attached to, adjusting the limit to the
+// latencies of the calls, so that the throughput shrinks as the service degrades before the circuit has to open
+//
+// The limit starts at InitialLimit(MaxLimit if zero) and is adjusted with every call the service responds to(additive
+// increase, multiplicative decrease). A call that fails or takes longer than LatencyThreshold cuts the limit by
+// BackOffRatio(0.9 if zero), down to MinLimit(one if zero). Any other call, made while the limit was in use, raises it
+// by one every limit calls, up to MaxLimit. If no LatencyThreshold is provided, it is Tolerance(two if zero) times the
+// lowest latency of the last hundred or so successful calls, so that it follows the service as its latency shifts.
+//
+// A call finding the limit reached is rejected with ErrConcurrencyLimitExceeded and served by the fallbacks, it is not
+// counted as a failure of the service.
+//
+// Example:
+//
+//  cb := cutout.NewCircuitBreaker(5, 15*time.Second)
+//  cb.AdaptiveLimiter = cutout.NewAdaptiveLimiter(5, 100)
+//  cb.AdaptiveLimiter.LatencyThreshold = 250 * time.Millisecond
+type AdaptiveLimiter struct {
+	InitialLimit     int
+	MinLimit         int
+	MaxLimit         int
+	LatencyThreshold time.Duration
+	Tolerance        float64
+	BackOffRatio     float64
+	mu               sync.Mutex
+	limit            float64
+	inFlight         int
+	minLatency       time.Duration
+	prevMinLatency   time.Duration
+	latencySamples   int
+}
+
+// the number of successful calls the lowest latency is taken over, the lowest latency of the previous window is kept
+// along with the current one
+const adaptiveLatencyWindow = 100
+
+// NewAdaptiveLimiter creates a new adaptive limiter keeping the limit between minLimit and maxLimit
+func NewAdaptiveLimiter(minLimit, maxLimit int) *AdaptiveLimiter {
+	return &AdaptiveLimiter{
+		MinLimit: minLimit,
+		MaxLimit: maxLimit,
+	}
+}
+
+// Limit returns the current limit of the calls in flight
+func (l *AdaptiveLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.currentLimit())
+}
+
+// InFlight returns the number of calls in flight
+func (l *AdaptiveLimiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight
+}
+
+func (l *AdaptiveLimiter) minLimit() float64 {
+	if l.MinLimit > 0 {
+		return float64(l.MinLimit)
+	}
+	return 1
+}
+
+func (l *AdaptiveLimiter) maxLimit() float64 {
+	if l.MaxLimit > 0 && float64(l.MaxLimit) > l.minLimit() {
+		return float64(l.MaxLimit)
+	}
+	return l.minLimit()
+}
+
+// the current limit, must be called with the lock held
+func (l *AdaptiveLimiter) currentLimit() float64 {
+	if l.limit == 0 {
+		l.limit = l.maxLimit()
+		if l.InitialLimit > 0 {
+			l.limit = float64(l.InitialLimit)
+		}
+		l.clamp()
+	}
+	return l.limit
+}
+
+func (l *AdaptiveLimiter) clamp() {
+	if l.limit < l.minLimit() {
+		l.limit = l.minLimit()
+	}
+	if l.limit > l.maxLimit() {
+		l.limit = l.maxLimit()
+	}
+}
+
+// let a call through if the limit allows, the returned function must be called once the call is done
+func (l *AdaptiveLimiter) acquire() (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if float64(l.inFlight) >= l.currentLimit() {
+		return nil, ErrConcurrencyLimitExceeded
+	}
+	l.inFlight++
+
+	return func() {
+		l.mu.Lock()
+		l.inFlight--
+		l.mu.Unlock()
+	}, nil
+}
+
+// the latency above which a call is taken as a sign of degradation, must be called with the lock held
+func (l *AdaptiveLimiter) latencyThreshold() time.Duration {
+	if l.LatencyThreshold > 0 {
+		return l.LatencyThreshold
+	}
+
+	tolerance := l.Tolerance
+	if tolerance <= 0 {
+		tolerance = 2
+	}
+	return time.Duration(float64(l.baseline()) * tolerance)
+}
+
+// the lowest latency of the successful calls of the current & the previous windows, must be called with the lock held
+func (l *AdaptiveLimiter) baseline() time.Duration {
+	if l.prevMinLatency > 0 && (l.minLatency == 0 || l.prevMinLatency < l.minLatency) {
+		return l.prevMinLatency
+	}
+	return l.minLatency
+}
+
+// record the latency of a successful call, must be called with the lock held
+func (l *AdaptiveLimiter) recordLatency(d time.Duration) {
+	if l.minLatency == 0 || d < l.minLatency {
+		l.minLatency = d
+	}
+
+	l.latencySamples++
+	if l.latencySamples >= adaptiveLatencyWindow {
+		l.prevMinLatency, l.minLatency, l.latencySamples = l.minLatency, 0, 0
+	}
+}
+
+// adjust the limit to the outcome of a call the service responded to
+func (l *AdaptiveLimiter) update(o Outcome) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limit := l.currentLimit()
+	if o.Err == nil && o.Duration > 0 { // a failure, however fast, says nothing about how fast the service is
+		l.recordLatency(o.Duration)
+	}
+
+	if o.Err != nil || o.Duration > l.latencyThreshold() {
+		ratio := l.BackOffRatio
+		if ratio <= 0 || ratio >= 1 {
+			ratio = 0.9
+		}
+		l.limit = limit * ratio
+	} else if float64(l.inFlight)*2 >= limit { // no point raising a limit which is not in use
+		l.limit = limit + 1/limit
+	}
+	l.clamp()
+}
@@ -0,0 +1,151 @@
+package cutout
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveLimiterUpdate(t *testing.T) {
+	l := NewAdaptiveLimiter(2, 10)
+	l.LatencyThreshold = 100 * time.Millisecond
+
+	if limit := l.Limit(); limit != 10 {
+		t.Fatalf("Expected the limit to start at %d, got %d", 10, limit)
+	}
+
+	for i := 0; i < 20; i++ {
+		l.update(Outcome{Duration: time.Second})
+	}
+	if limit := l.Limit(); limit != 2 {
+		t.Errorf("The slow calls should have cut the limit down to %d, got %d", 2, limit)
+	}
+
+	l.update(Outcome{Duration: time.Millisecond})
+	if limit := l.Limit(); limit != 2 {
+		t.Errorf("The limit not in use should not be raised, got %d", limit)
+	}
+
+	for i := 0; i < 2; i++ {
+		release, err := l.acquire()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer release()
+	}
+	if _, err := l.acquire(); !errors.Is(err, ErrConcurrencyLimitExceeded) {
+		t.Errorf("Expected %v, got %v", ErrConcurrencyLimitExceeded, err)
+	}
+	for i := 0; i < 10; i++ {
+		l.update(Outcome{Duration: time.Millisecond})
+	}
+	if limit := l.Limit(); limit <= 2 {
+		t.Errorf("The fast calls should have raised the limit, got %d", limit)
+	}
+
+	limit := l.Limit()
+	l.update(Outcome{Duration: time.Millisecond, Err: errors.New("failed")})
+	if l.Limit() >= limit {
+		t.Errorf("The failure should have cut the limit below %d, got %d", limit, l.Limit())
+	}
+}
+
+func TestAdaptiveLimiterLatencyBaseline(t *testing.T) {
+	l := &AdaptiveLimiter{MaxLimit: 10, Tolerance: 2}
+
+	l.update(Outcome{Duration: 10 * time.Millisecond})
+	l.update(Outcome{Duration: 15 * time.Millisecond})
+	if limit := l.Limit(); limit != 10 {
+		t.Errorf("Calls within the tolerance should keep the limit, got %d", limit)
+	}
+
+	l.update(Outcome{Duration: 50 * time.Millisecond})
+	if limit := l.Limit(); limit != 9 {
+		t.Errorf("Expected the limit to be cut to %d, got %d", 9, limit)
+	}
+}
+
+func TestAdaptiveLimiterRecovers(t *testing.T) {
+	l := &AdaptiveLimiter{MaxLimit: 10}
+	l.inFlight = 10 // the limit in use all along
+
+	for i := 0; i < 20; i++ {
+		l.update(Outcome{Duration: 50 * time.Microsecond, Err: errors.New("connection refused")})
+	}
+	if limit := l.Limit(); limit != 1 {
+		t.Fatalf("The failures should have cut the limit down to %d, got %d", 1, limit)
+	}
+
+	for i := 0; i < 1000; i++ {
+		l.update(Outcome{Duration: 5 * time.Millisecond})
+	}
+	if limit := l.Limit(); limit != 10 {
+		t.Errorf("The limit should have been raised back to %d once the service recovered, got %d", 10, limit)
+	}
+}
+
+func TestAdaptiveLimiterLatencyShift(t *testing.T) {
+	l := &AdaptiveLimiter{MaxLimit: 10}
+	l.inFlight = 10
+
+	for i := 0; i < 10; i++ {
+		l.update(Outcome{Duration: time.Millisecond})
+	}
+	for i := 0; i < 3*adaptiveLatencyWindow; i++ {
+		l.update(Outcome{Duration: 10 * time.Millisecond})
+	}
+	if limit := l.Limit(); limit != 10 {
+		t.Errorf("The baseline should have followed the latency of the service, got a limit of %d", limit)
+	}
+}
+
+func TestAdaptiveLimiterRejectsToFallbacks(t *testing.T) {
+	cb := NewCircuitBreaker(3, time.Second)
+	cb.AdaptiveLimiter = &AdaptiveLimiter{InitialLimit: 2, MaxLimit: 10, LatencyThreshold: time.Second}
+	cb.InitAnalytics()
+
+	ts := newTestService(http.StatusOK, true)
+	defer ts.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cb.Call(ts.request()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	for atomic.LoadInt32(&ts.hits) < 2 {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if _, err := cb.Call(ts.request()); !errors.Is(err, ErrConcurrencyLimitExceeded) {
+		t.Errorf("Expected %v, got %v", ErrConcurrencyLimitExceeded, err)
+	}
+	resp, err := cb.Call(ts.request(), cacheFallback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.BodyString != "cache" {
+		t.Errorf("Expected the fallback response, got %q", resp.BodyString)
+	}
+
+	ts.release()
+	wg.Wait()
+
+	if inFlight := cb.AdaptiveLimiter.InFlight(); inFlight != 0 {
+		t.Errorf("Expected %d calls in flight, got %d", 0, inFlight)
+	}
+	a := cb.GetAnalytics()
+	if a.ConcurrencyLimitRejections != 2 {
+		t.Errorf("Expected %d rejections, got %d", 2, a.ConcurrencyLimitRejections)
+	}
+	if a.TotalFailures != 0 || cb.FailCount() != 0 {
+		t.Errorf("Rejections should not count as failures, got %d failures", a.TotalFailures)
+	}
+}
@@ -1,87 +1,269 @@
 package cutout
 
-import "time"
+import (
+	"errors"
+	"time"
+)
 
 type (
 
 	// Failure holds the failure instance information
 	Failure struct {
 		Message       string    `json:"message"`
+		StatusCode    int       `json:"status_code,omitempty"`
 		OccurredAt    time.Time `json:"occurred_at"`
 		TotalFailures int       `json:"total_failures"`
 	}
 
 	// RequestRecord holds the information of a request incident
 	RequestRecord struct {
-		Name        string    `json:"name"`
-		Method      string    `json:"method"`
-		StatusCode  int       `json:"status_code"`
-		StatusText  string    `json:"status_text"`
-		Message     string    `json:"message"`
-		RequestedAt time.Time `json:"requested_at"`
+		Breaker     string        `json:"breaker,omitempty"`
+		Name        string        `json:"name"`
+		Method      string        `json:"method"`
+		StatusCode  int           `json:"status_code"`
+		StatusText  string        `json:"status_text"`
+		Message     string        `json:"message"`
+		Attempt     int           `json:"attempt,omitempty"`
+		Hedge       bool          `json:"hedge,omitempty"`
+		RequestedAt time.Time     `json:"requested_at"`
+		Latency     time.Duration `json:"latency"`
 	}
 
 	// Analytics contains analytical informations regarding the circuit breaker
+	//
+	// All the failures & request records are kept unless the retention is limited when the analytics is initialized,
+	// e.g, a long running service would keep the records of the last hour or so only. The counters are lifetime totals
+	// regardless.
+	//
+	// Rolling holds the counts & rates of the calls made within the last minute or so(see WithRollingWindow).
+	//
+	// Latency holds the latencies of all the requests sent, RollingLatency of the ones sent within the rolling window.
 	Analytics struct {
-		RequestSent    int             `json:"request_sent"`
-		TotalFailures  int             `json:"total_failures"`
-		FallbackCalls  int             `json:"fallback_calls"`
-		Failures       []Failure       `json:"failures"`
-		TotalCalls     int             `json:"total_calls"`
-		SuccessRate    float64         `json:"success_rate"`
-		FailureRate    float64         `json:"failure_rate"`
-		RequestRecords []RequestRecord `json:"request_records"`
+		RequestSent                int             `json:"request_sent"`
+		TotalFailures              int             `json:"total_failures"`
+		FallbackCalls              int             `json:"fallback_calls"`
+		SlowCalls                  int             `json:"slow_calls"`
+		CancelledCalls             int             `json:"cancelled_calls"`
+		Retries                    int             `json:"retries"`
+		Hedges                     int             `json:"hedges"`
+		BulkheadRejections         int             `json:"bulkhead_rejections"`
+		RateLimitRejections        int             `json:"rate_limit_rejections"`
+		ConcurrencyLimitRejections int             `json:"concurrency_limit_rejections"`
+		CircuitRejections          int             `json:"circuit_rejections"`
+		Failures                   []Failure       `json:"failures"`
+		TotalCalls                 int             `json:"total_calls"`
+		SuccessRate                float64         `json:"success_rate"`
+		FailureRate                float64         `json:"failure_rate"`
+		RequestRecords             []RequestRecord `json:"request_records"`
+		Rolling                    RollingCounts   `json:"rolling"`
+		Latency                    *Histogram      `json:"latency"`
+		RollingLatency             *Histogram      `json:"rolling_latency"`
+		rolling                    *rollingCounter
+		rollingWindow              time.Duration
+		failures                   *history[Failure]
+		requestRecords             *history[RequestRecord]
+		maxRecords                 int
+		maxAge                     time.Duration
+		maxMessageLength           int
+		omitMessages               bool
 	}
 )
 
 // InitAnalytics initializes the analytics instance for the circu breaker to start analyzing
-func (c *CircuitBreaker) InitAnalytics() {
-	c.analytics = &Analytics{}
+//
+// Example:
+//
+//  // the request records of the last hour, up to 500 of them, without the response bodies
+//  cb.InitAnalytics(cutout.WithMaxRecords(500), cutout.WithMaxAge(time.Hour), cutout.WithoutMessages())
+func (c *CircuitBreaker) InitAnalytics(opts ...AnalyticsOption) {
+	anlcts := &Analytics{Latency: &Histogram{}}
+	for _, opt := range opts {
+		opt(anlcts)
+	}
+	anlcts.failures = newHistory[Failure](anlcts.maxRecords, anlcts.maxAge)
+	anlcts.requestRecords = newHistory[RequestRecord](anlcts.maxRecords, anlcts.maxAge)
+	anlcts.rolling = newRollingCounter(anlcts.rollingWindow)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.analytics = anlcts
 }
 
-// GetAnalytics returns the analytics instance of the circuit breaker
+// GetAnalytics returns a snapshot of the analytics instance of the circuit breaker, the snapshot is not
+// affected by the calls made afterwards
 func (c *CircuitBreaker) GetAnalytics() *Analytics {
-	return c.analytics
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.analyticsSnapshot()
+}
+
+// copy the analytics, must be called with the circuit breaker lock held
+func (c *CircuitBreaker) analyticsSnapshot() *Analytics {
+	if c.analytics == nil {
+		return nil
+	}
+
+	now := time.Now()
+	return &Analytics{
+		RequestSent:                c.analytics.RequestSent,
+		TotalFailures:              c.analytics.TotalFailures,
+		FallbackCalls:              c.analytics.FallbackCalls,
+		SlowCalls:                  c.analytics.SlowCalls,
+		CancelledCalls:             c.analytics.CancelledCalls,
+		Retries:                    c.analytics.Retries,
+		Hedges:                     c.analytics.Hedges,
+		BulkheadRejections:         c.analytics.BulkheadRejections,
+		RateLimitRejections:        c.analytics.RateLimitRejections,
+		ConcurrencyLimitRejections: c.analytics.ConcurrencyLimitRejections,
+		CircuitRejections:          c.analytics.CircuitRejections,
+		Failures:                   c.analytics.failures.records(now),
+		TotalCalls:                 c.analytics.TotalCalls,
+		SuccessRate:                c.analytics.SuccessRate,
+		FailureRate:                c.analytics.FailureRate,
+		RequestRecords:             c.analytics.requestRecords.records(now),
+		Rolling:                    c.analytics.rolling.counts(now),
+		Latency:                    c.analytics.Latency.Clone(),
+		RollingLatency:             c.analytics.rolling.latency(now),
+	}
 }
 
-func (c *CircuitBreaker) updateAnalyticsFailure(errMsg string) {
+// the analytics updaters below must be called with the circuit breaker lock held
+
+func (c *CircuitBreaker) updateAnalyticsFailure(err error) {
 	if c.analytics != nil {
 		c.analytics.TotalFailures++
-		c.analytics.Failures = append(c.analytics.Failures, Failure{
-			Message:       errMsg,
+		f := Failure{
+			Message:       err.Error(),
 			OccurredAt:    time.Now(),
 			TotalFailures: c.analytics.TotalFailures + 1,
-		})
+		}
+		var statusErr *HTTPStatusError
+		if errors.As(err, &statusErr) {
+			f.StatusCode = statusErr.StatusCode
+		}
+		c.analytics.failures.add(f.OccurredAt, f)
+
+		counts := c.analytics.rolling.bucket(f.OccurredAt)
+		counts.Failures++
+		if isTimeout(err) {
+			counts.Timeouts++
+		}
 	}
 }
 
-func (c *CircuitBreaker) updateAnalyticsRequestAndResponse(url, method string, reqTime time.Time, resp *Response) {
+func (c *CircuitBreaker) updateAnalyticsRequestRecord(rr RequestRecord) {
 	if c.analytics != nil {
 		c.analytics.RequestSent++
-		rr := RequestRecord{
-			Name:        url,
-			Method:      method,
-			RequestedAt: reqTime,
-		}
-		if resp != nil {
-			rr.StatusCode = resp.StatusCode
-			rr.StatusText = resp.Status
-			rr.Message = resp.BodyString
+		c.analytics.Latency.Record(rr.Latency)
+		c.analytics.rolling.bucket(rr.RequestedAt.Add(rr.Latency)).latency.Record(rr.Latency)
+		if c.analytics.omitMessages {
+			rr.Message = ""
+		} else if c.analytics.maxMessageLength > 0 {
+			rr.Message = truncate(rr.Message, c.analytics.maxMessageLength)
 		}
-		c.analytics.RequestRecords = append(c.analytics.RequestRecords, rr)
+		c.analytics.requestRecords.add(time.Now(), rr)
+	}
+}
+
+func (c *CircuitBreaker) addAnalyticsSuccessCount() {
+	if c.analytics != nil {
+		c.analytics.rolling.bucket(time.Now()).Successes++
+	}
+}
+
+func (c *CircuitBreaker) addAnalyticsRejectionCount() {
+	if c.analytics != nil {
+		c.analytics.rolling.bucket(time.Now()).Rejections++
+	}
+}
+
+func (c *CircuitBreaker) addAnalyticsSlowCallCount() {
+	if c.analytics != nil {
+		c.analytics.SlowCalls++
+	}
+}
+
+func (c *CircuitBreaker) addAnalyticsCancelledCount() {
+	if c.analytics != nil {
+		c.analytics.CancelledCalls++
+	}
+}
+
+func (c *CircuitBreaker) addAnalyticsRetryCount() {
+	if c.analytics != nil {
+		c.analytics.Retries++
+	}
+}
+
+func (c *CircuitBreaker) addAnalyticsHedgeCount() {
+	if c.analytics != nil {
+		c.analytics.Hedges++
+	}
+}
+
+func (c *CircuitBreaker) addAnalyticsBulkheadRejectionCount() {
+	if c.analytics != nil {
+		c.analytics.BulkheadRejections++
+	}
+}
+
+func (c *CircuitBreaker) addAnalyticsRateLimitRejectionCount() {
+	if c.analytics != nil {
+		c.analytics.RateLimitRejections++
+	}
+}
+
+func (c *CircuitBreaker) addAnalyticsConcurrencyLimitRejectionCount() {
+	if c.analytics != nil {
+		c.analytics.ConcurrencyLimitRejections++
+	}
+}
+
+func (c *CircuitBreaker) addAnalyticsCircuitRejectionCount() {
+	if c.analytics != nil {
+		c.analytics.CircuitRejections++
 	}
 }
 
 func (c *CircuitBreaker) addAnalyticsFallbackCount() {
 	if c.analytics != nil {
 		c.analytics.FallbackCalls++
+		c.analytics.rolling.bucket(time.Now()).Fallbacks++
 	}
 }
 
-func (c *CircuitBreaker) updateAnalyticsRates() {
+func (c *CircuitBreaker) addAnalyticsCallCount() {
 	if c.analytics != nil {
 		c.analytics.TotalCalls++
-		c.analytics.SuccessRate = float64(c.analytics.RequestSent-c.analytics.TotalFailures) / float64(c.analytics.RequestSent) * 100
-		c.analytics.FailureRate = 100 - c.analytics.SuccessRate
+	}
+}
+
+func (c *CircuitBreaker) updateAnalyticsRates() {
+	if c.analytics != nil {
+		if c.analytics.RequestSent > 0 { // the calls may all have been rejected so far
+			c.analytics.SuccessRate = float64(c.analytics.RequestSent-c.analytics.TotalFailures) / float64(c.analytics.RequestSent) * 100
+			c.analytics.FailureRate = 100 - c.analytics.SuccessRate
+		}
+		c.analytics.Rolling = c.analytics.rolling.counts(time.Now())
+	}
+}
+
+// describes an http call in its request record
+func describeHTTPCall(url, method string) func(*RequestRecord, *Response) {
+	return describeHTTPAttempt(url, method, 0)
+}
+
+// describes an attempt of an http call in its request record
+func describeHTTPAttempt(url, method string, attempt int) func(*RequestRecord, *Response) {
+	return func(rr *RequestRecord, resp *Response) {
+		rr.Attempt = attempt
+		rr.Name = url
+		rr.Method = method
+		if resp != nil {
+			rr.StatusCode = resp.StatusCode
+			rr.StatusText = resp.Status
+			rr.Message = resp.BodyString
+		}
 	}
 }
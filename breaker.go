@@ -1,19 +1,71 @@
 package cutout
 
 import (
+	"context"
 	"net/http"
+	"sync"
 	"time"
 )
 
 // CircuitBreaker is the circuit breaker!!!
+//
+// A CircuitBreaker is safe for concurrent use by multiple goroutines, so a single instance can be shared
+// by all the handlers calling the same service.
+//
+// While the circuit is HALF_OPEN, at most HalfOpenMaxRequests trial requests are let through at a time(no limit if
+// zero), the rest are served by the fallbacks. The circuit closes after HalfOpenSuccessThreshold consecutive successful
+// trial requests(one if zero).
+//
+// The circuit opens when its TripPolicy says so, if no policy is provided, it opens after FailThreshold consecutive
+// failures.
+//
+// Calls taking longer than SlowCallThreshold(if provided) are recorded as slow calls, they can open the circuit with
+// the SlowCallRate policy.
+//
+// At most MaxConcurrentCalls calls are in flight at a time(no limit if zero), so that a slow service can't take up all
+// the goroutines and connections before the circuit opens. Up to MaxWaitingCalls of the excess calls wait for a slot to
+// free up, for MaxWaitDuration at most(as long as their context allows if zero), the rest are served by the fallbacks.
+//
+// The calls are limited to the rate of the RateLimiter and the concurrency of the AdaptiveLimiter, if provided.
+//
+// The calls are observed by the Instrumentation, if provided, e.g, to trace them.
+//
+// The Name of the circuit breaker, if any, identifies it in the events and the request records.
+//
+// The http calls are made with the Client of the circuit breaker, DefaultClient if none is provided. To plug in a
+// custom transport(TLS config, proxies, keep-alive tuning etc.), provide a Client with that transport.
 type CircuitBreaker struct {
-	FailThreshold     int
-	HealthCheckPeriod time.Duration
-	events            chan string
-	state             string
-	lastFailed        *time.Time
-	failCount         int
-	analytics         *Analytics
+	Name                     string
+	FailThreshold            int
+	HealthCheckPeriod        time.Duration
+	HalfOpenMaxRequests      int
+	HalfOpenSuccessThreshold int
+	TripPolicy               TripPolicy
+	SlowCallThreshold        time.Duration
+	Client                   *http.Client
+	MaxConcurrentCalls       int
+	MaxWaitingCalls          int
+	MaxWaitDuration          time.Duration
+	RateLimiter              *RateLimiter
+	AdaptiveLimiter          *AdaptiveLimiter
+	Instrumentation          Instrumentation
+	mu                       sync.Mutex
+	events                   chan string
+	eventStream              chan Event
+	eventQueue               []Event
+	eventMu                  sync.Mutex
+	deliverMu                sync.Mutex
+	state                    string
+	generation               uint64
+	lastFailed               *time.Time
+	openedAt                 *time.Time
+	failCount                int
+	halfOpenRequests         int
+	halfOpenSuccesses        int
+	noResponseCount          int
+	bulkhead                 chan struct{}
+	waitingCalls             int
+	analytics                *Analytics
 }
 
 // NewCircuitBreaker creates a new circuit breaker
@@ -24,6 +76,43 @@ func NewCircuitBreaker(failThreshold int, healthCheckPeriod time.Duration) *Circ
 	}
 }
 
+// NewCircuitBreakerWithWindow creates a new circuit breaker which opens based on the failure rate over a sliding window
+//
+// Example:
+//
+//  // opens when half of the last 100 calls have failed, given that at least 20 calls were made
+//  cb := cutout.NewCircuitBreakerWithWindow(cutout.SlidingWindow{
+// 	 Size:        100,
+// 	 MinCalls:    20,
+// 	 FailureRate: 50,
+//  }, 15*time.Second)
+func NewCircuitBreakerWithWindow(window SlidingWindow, healthCheckPeriod time.Duration) *CircuitBreaker {
+	return NewCircuitBreakerWithPolicy(FailureRate(window), healthCheckPeriod)
+}
+
+// NewCircuitBreakerWithPolicy creates a new circuit breaker which opens when the given policy says so
+//
+// Example:
+//
+//  cb := cutout.NewCircuitBreakerWithPolicy(cutout.TripOnAny(
+// 	 cutout.ConsecutiveFailures(10),
+// 	 cutout.FailureRate(cutout.SlidingWindow{Duration: time.Minute, MinCalls: 20, FailureRate: 50}),
+//  ), 15*time.Second)
+func NewCircuitBreakerWithPolicy(policy TripPolicy, healthCheckPeriod time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		HealthCheckPeriod: healthCheckPeriod,
+		TripPolicy:        policy,
+	}
+}
+
+// the http client to make the calls with
+func (c *CircuitBreaker) client() *http.Client {
+	if c.Client != nil {
+		return c.Client
+	}
+	return DefaultClient
+}
+
 // Call calls an external service using the circuit breaker design
 //
 // Parameters:
@@ -32,6 +121,10 @@ func NewCircuitBreaker(failThreshold int, healthCheckPeriod time.Duration) *Circ
 //
 // 2. ...func()(*Response , error) -----> one or many fallback functions which must return a *cutout.Response & error instance
 //
+// If the call is not let through and no fallback functions are provided, the reason is returned(ErrCircuitOpen,
+// ErrTooManyHalfOpenRequests, ErrBulkheadFull, ErrRateLimited or ErrConcurrencyLimitExceeded), if all the fallback
+// functions fail, ErrAllFallbacksFailed is returned
+//
 // Example:
 //
 //  resp, err := cb.Call(&req, func() (*cutout.Response, error) {
@@ -40,32 +133,27 @@ func NewCircuitBreaker(failThreshold int, healthCheckPeriod time.Duration) *Circ
 //   }, nil
 //  })
 func (c *CircuitBreaker) Call(req *Request, fallbackFuncs ...func() (*Response, error)) (*Response, error) {
-	c.setState()
-
-	var resp *Response
-	var err error
-
-	switch c.state {
-	case ClosedState, HalfOpenState:
-		reqTimeForAnlcts := time.Now()
-		resp, err = req.makeRequest()
-		if err != nil {
-			c.updateFailData()
-			c.updateAnalyticsFailure(err.Error())
-		} else {
-			c.resetCircuit()
-		}
-		c.updateAnalyticsRequestAndResponse(req.URL, req.Method, reqTimeForAnlcts, resp)
-	case OpenState:
-		resp, err = executeFallbacks(fallbackFuncs)
-		if err != nil {
-			return resp, err
-		}
-		c.addAnalyticsFallbackCount()
-	}
-
-	c.updateAnalyticsRates()
+	return c.CallContext(context.Background(), req, fallbackFuncs...)
+}
 
+// CallContext is like Call, but the request is sent within the given context, i.e, it is cancelled along with the
+// context and its TimeOut is cut short by the deadline of the context.
+//
+// A call failing because the context got cancelled or exceeded its deadline is not counted as a failure of the
+// service, the caller has given up on it.
+//
+// Example:
+//
+//  func thehandler(w http.ResponseWriter, r *http.Request) {
+// 	 resp, err := cb.CallContext(r.Context(), &req, theFallbackFunc)
+// 	 ...
+//  }
+func (c *CircuitBreaker) CallContext(ctx context.Context, req *Request,
+	fallbackFuncs ...func() (*Response, error)) (*Response, error) {
+	ctx, endCall := c.startCall(ctx, req.URL, req.Method)
+	resp, err := c.callWithRetries(ctx, req.withHeaders(c.injectHeaders(ctx)), fallbackFuncs)
+	c.countCall()
+	endCall(resp, err)
 	return resp, err
 }
 
@@ -79,6 +167,10 @@ func (c *CircuitBreaker) Call(req *Request, fallbackFuncs ...func() (*Response,
 //
 // 3. ...func()(*Response , error) -----> one or many fallback functions which must return a *cutout.Response & error instance
 //
+// If the call is not let through and no fallback functions are provided, the reason is returned(ErrCircuitOpen,
+// ErrTooManyHalfOpenRequests, ErrBulkheadFull, ErrRateLimited or ErrConcurrencyLimitExceeded), if all the fallback
+// functions fail, ErrAllFallbacksFailed is returned
+//
 // Example:
 //
 //  req, err := http.NewRequest(http.MethodGet, url, nil)
@@ -99,31 +191,34 @@ func (c *CircuitBreaker) Call(req *Request, fallbackFuncs ...func() (*Response,
 //  })
 func (c *CircuitBreaker) CallWithCustomRequest(req *http.Request, allowedStatus []int,
 	fallbackFuncs ...func() (*Response, error)) (*Response, error) {
-	c.setState()
+	ctx, endCall := c.startCall(req.Context(), req.URL.String(), req.Method)
+	sent := withHeaders(req.WithContext(ctx), c.injectHeaders(ctx))
 
-	var resp *Response
-	var err error
-
-	switch c.state {
-	case ClosedState, HalfOpenState:
-		reqTimeForAnlcts := time.Now()
-		resp, err = makeCustomRequest(req, allowedStatus)
-		if err != nil {
-			c.updateFailData()
-			c.updateAnalyticsFailure(err.Error())
-		} else {
-			c.resetCircuit()
-		}
-		c.updateAnalyticsRequestAndResponse(req.URL.String(), req.Method, reqTimeForAnlcts, resp)
-	case OpenState:
-		resp, err = executeFallbacks(fallbackFuncs)
-		if err != nil {
-			return resp, err
-		}
-		c.addAnalyticsFallbackCount()
-	}
+	resp, err := execute(withCallInfo(context.Background(), ctx), c, func(context.Context) (*Response, error) {
+		return makeCustomRequest(sent, allowedStatus, c.client())
+	}, describeHTTPCall(req.URL.String(), req.Method), fallbackFuncs)
+	endCall(resp, err)
+	return resp, err
+}
 
-	c.updateAnalyticsRates()
+// CallWithCustomRequestContext is like CallWithCustomRequest, but the request is sent within the given context. The
+// deadline of the request's own context, if any, still applies.
+//
+// A call failing because the given context got cancelled or exceeded its deadline is not counted as a failure of the
+// service, the caller has given up on it.
+func (c *CircuitBreaker) CallWithCustomRequestContext(ctx context.Context, req *http.Request, allowedStatus []int,
+	fallbackFuncs ...func() (*Response, error)) (*Response, error) {
+	ctx, endCall := c.startCall(ctx, req.URL.String(), req.Method)
+	sent := withHeaders(req, c.injectHeaders(ctx))
 
+	resp, err := execute(ctx, c, func(ctx context.Context) (*Response, error) {
+		if deadline, ok := req.Context().Deadline(); ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadline(ctx, deadline)
+			defer cancel()
+		}
+		return makeCustomRequest(sent.WithContext(ctx), allowedStatus, c.client())
+	}, describeHTTPCall(req.URL.String(), req.Method), fallbackFuncs)
+	endCall(resp, err)
 	return resp, err
 }
@@ -3,6 +3,7 @@ package cutout
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -10,6 +11,8 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -22,6 +25,7 @@ const (
 
 type (
 	testEventInfo struct {
+		mu           sync.Mutex
 		currentState *string
 		failed       bool
 	}
@@ -41,9 +45,13 @@ func TestCircuitBreakerCall(t *testing.T) {
 			switch <-event {
 			case StateChangeEvent:
 				state := cb.State()
+				tei.mu.Lock()
 				tei.currentState = &state
+				tei.mu.Unlock()
 			case FailureEvent:
+				tei.mu.Lock()
 				tei.failed = true
+				tei.mu.Unlock()
 			}
 		}
 	}()
@@ -57,7 +65,7 @@ func TestCircuitBreakerCall(t *testing.T) {
 	handler := testCallHandler(ts.URL, cb, cache)
 
 	if err := startIntegrationTest(t, mockServerURL, ts, cb, handler, tei); err != nil {
-		t.Errorf(err.Error())
+		t.Error(err.Error())
 	}
 
 }
@@ -76,9 +84,13 @@ func TestCircuitBreakerCallWithCustomRequest(t *testing.T) {
 			switch <-event {
 			case StateChangeEvent:
 				state := cb.State()
+				tei.mu.Lock()
 				tei.currentState = &state
+				tei.mu.Unlock()
 			case FailureEvent:
+				tei.mu.Lock()
 				tei.failed = true
+				tei.mu.Unlock()
 			}
 		}
 	}()
@@ -92,7 +104,7 @@ func TestCircuitBreakerCallWithCustomRequest(t *testing.T) {
 	handler := testCallWithCustomRequestHandler(ts.URL, cb, cache)
 
 	if err := startIntegrationTest(t, mockServerURLCustom, ts, cb, handler, tei); err != nil {
-		t.Errorf(err.Error())
+		t.Error(err.Error())
 	}
 
 }
@@ -168,14 +180,14 @@ func startIntegrationTest(t *testing.T, url string, ts *httptest.Server, cb *Cir
 	// # Checking half open state #
 	// ############################
 	t.Log("Checking half open state...")
-	t.Logf("state:%s, status:%d, fail count:%d\n", HalfOpenState, http.StatusInternalServerError, cb.FailThreshold+1)
+	t.Logf("state:%s, status:%d, fail count:%d\n", OpenState, http.StatusInternalServerError, cb.FailThreshold+1)
 	respStatusCode := testCall(handler).StatusCode
 	if err := checkErrors(http.StatusInternalServerError, respStatusCode,
-		HalfOpenState, cb.State(), cb.FailThreshold+1, cb.FailCount()); err != nil {
+		OpenState, cb.State(), cb.FailThreshold+1, cb.FailCount()); err != nil { // the failed trial reopens the circuit
 		return err
 	}
 	time.Sleep(50 * time.Millisecond)
-	if err := checkEvents(tei, HalfOpenState, true); err != nil {
+	if err := checkEvents(tei, OpenState, true); err != nil {
 		return err
 	}
 
@@ -294,7 +306,7 @@ func testServer(url string) *httptest.Server {
 
 		bb, _ := json.Marshal(stdnt)
 		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, string(bb))
+		fmt.Fprint(w, string(bb))
 	}))
 
 	lstnr, err := net.Listen("tcp", url)
@@ -374,6 +386,9 @@ func testCallWithCustomRequestHandler(url string, cb *CircuitBreaker, cache stri
 }
 
 func checkEvents(tei *testEventInfo, wantState string, wantFail bool) error {
+	tei.mu.Lock()
+	defer tei.mu.Unlock()
+
 	if tei.currentState == nil {
 		return fmt.Errorf("Invalid state received, wanted:%s, got:%v", wantState, tei.currentState)
 	}
@@ -423,3 +438,182 @@ func checkAnalytics(anlcts *Analytics) error {
 	return nil
 
 }
+
+func TestCircuitBreakerConcurrentCalls(t *testing.T) {
+	const goroutines = 300
+
+	var healthy int32 = 1
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&healthy) == 1 {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	cb := NewCircuitBreaker(10, 500*time.Millisecond)
+	cb.InitAnalytics()
+
+	event := make(chan string, 1)
+	cb.InitEvent(event)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-event:
+				cb.State()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	fallback := func() (*Response, error) {
+		return &Response{BodyString: "cache"}, nil
+	}
+
+	hammer := func() {
+		var wg sync.WaitGroup
+		for i := 0; i < goroutines; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if i%2 == 0 {
+					req := Request{
+						URL:           ts.URL,
+						Method:        http.MethodGet,
+						AllowedStatus: []int{http.StatusOK},
+						TimeOut:       2 * time.Second,
+					}
+					cb.Call(&req, fallback)
+				} else {
+					req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+					cb.CallWithCustomRequest(req, []int{http.StatusOK}, fallback)
+				}
+				cb.State()
+				cb.FailCount()
+				cb.LastFailed()
+				cb.GetAnalytics()
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	hammer()
+	if state := cb.State(); state != ClosedState {
+		t.Fatalf("Incorrect state of the circuit received, wanted %s got %s", ClosedState, state)
+	}
+
+	atomic.StoreInt32(&healthy, 0)
+	hammer()
+	hammer()
+	if state := cb.State(); state != OpenState {
+		t.Fatalf("Incorrect state of the circuit received, wanted %s got %s", OpenState, state)
+	}
+
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(2 * cb.HealthCheckPeriod)
+	hammer()
+	hammer()
+	if state := cb.State(); state != ClosedState {
+		t.Fatalf("Incorrect state of the circuit received, wanted %s got %s", ClosedState, state)
+	}
+
+	anlcts := cb.GetAnalytics()
+	if anlcts.TotalCalls != 5*goroutines {
+		t.Errorf("Invalid total calls, wanted:%d , got:%d", 5*goroutines, anlcts.TotalCalls)
+	}
+	if anlcts.RequestSent+anlcts.FallbackCalls != anlcts.TotalCalls {
+		t.Errorf("Request sent(%d) and fallback calls(%d) don't add up to the total calls(%d)",
+			anlcts.RequestSent, anlcts.FallbackCalls, anlcts.TotalCalls)
+	}
+	if len(anlcts.RequestRecords) != anlcts.RequestSent {
+		t.Errorf("Invalid number of request records, wanted:%d, got:%d", anlcts.RequestSent, len(anlcts.RequestRecords))
+	}
+	if anlcts.FallbackCalls == 0 {
+		t.Error("Fallbacks should have been called while the circuit was open")
+	}
+}
+
+func TestCircuitBreakerCallContext(t *testing.T) {
+	cb := NewCircuitBreaker(1, time.Minute)
+	cb.InitAnalytics()
+
+	ts := newTestService(http.StatusOK, false)
+	defer ts.Close()
+	ts.setLatency(200 * time.Millisecond)
+
+	// the deadline of the caller cuts the timeout of the request short
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := cb.CallContext(ctx, ts.request()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if took := time.Since(start); took > 150*time.Millisecond {
+		t.Errorf("The call should have ended with the deadline of the caller, took %v", took)
+	}
+
+	// the caller cancelling the call
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := cb.CallContext(ctx, ts.request()); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected %v, got %v", context.Canceled, err)
+	}
+
+	if cb.FailCount() != 0 {
+		t.Errorf("Calls given up by the caller shouldn't count as failures, got fail count %d", cb.FailCount())
+	}
+	anlcts := cb.GetAnalytics()
+	if anlcts.CancelledCalls != 2 || anlcts.TotalFailures != 0 {
+		t.Errorf("Invalid analytics, wanted 2 cancelled calls & no failures, got %d cancelled calls & %d failures",
+			anlcts.CancelledCalls, anlcts.TotalFailures)
+	}
+
+	// the timeout of the request itself is still a failure
+	req := ts.request()
+	req.TimeOut = 50 * time.Millisecond
+	if _, err := cb.CallContext(context.Background(), req); err == nil {
+		t.Error("The call should have timed out")
+	}
+	if cb.FailCount() != 1 {
+		t.Errorf("Fail count should have been %d, got %d", 1, cb.FailCount())
+	}
+
+	// a caller that already gave up doesn't even make the call
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := cb.CallContext(ctx, ts.request(), cacheFallback); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestCircuitBreakerCallWithCustomRequestContext(t *testing.T) {
+	cb := NewCircuitBreaker(1, time.Minute)
+
+	ts := newTestService(http.StatusOK, false)
+	defer ts.Close()
+	ts.setLatency(200 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	if _, err := cb.CallWithCustomRequestContext(ctx, req, []int{http.StatusOK}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if cb.FailCount() != 0 {
+		t.Errorf("Calls given up by the caller shouldn't count as failures, got fail count %d", cb.FailCount())
+	}
+
+	reqCtx, reqCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer reqCancel()
+	req = req.WithContext(reqCtx)
+	if _, err := cb.CallWithCustomRequestContext(context.Background(), req, []int{http.StatusOK}); err == nil {
+		t.Error("The call should have timed out with the deadline of the request")
+	}
+	if cb.FailCount() != 1 {
+		t.Errorf("Fail count should have been %d, got %d", 1, cb.FailCount())
+	}
+}
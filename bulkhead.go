@@ -0,0 +1,133 @@
+package cutout
+
+import (
+	"context"
+	"time"
+)
+
+// let a call into the bulkhead, if the circuit breaker has one. When all the slots are taken, the call waits in the
+// queue for one to free up if wait is true and there is room in the queue, otherwise it is rejected. The returned
+// function releases the slot taken by the call.
+func (c *CircuitBreaker) enterBulkhead(ctx context.Context, wait bool) (func(), error) {
+	if c.MaxConcurrentCalls <= 0 {
+		return func() {}, nil
+	}
+
+	c.mu.Lock()
+	if c.bulkhead == nil {
+		c.bulkhead = make(chan struct{}, c.MaxConcurrentCalls)
+	}
+	slots := c.bulkhead
+	c.mu.Unlock()
+
+	release := func() { <-slots }
+
+	select {
+	case slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	c.mu.Lock()
+	if !wait || c.waitingCalls >= c.MaxWaitingCalls { // no room in the queue
+		c.mu.Unlock()
+		return nil, ErrBulkheadFull
+	}
+	c.waitingCalls++
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		c.waitingCalls--
+		c.mu.Unlock()
+	}()
+
+	var timeout <-chan time.Time
+	if c.MaxWaitDuration > 0 {
+		timer := time.NewTimer(c.MaxWaitDuration)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case slots <- struct{}{}:
+		return release, nil
+	case <-timeout:
+		return nil, ErrBulkheadFull
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// let a call through the rate limiter, the bulkhead, the adaptive limiter and the circuit, returns the generation of
+// the circuit the call belongs to and the function to call once the call is done, or the reason the call is not let
+// through. The circuit is checked first, so that a call it would reject neither takes a token nor waits for one, a
+// call rejected after taking a token gives it back.
+func (c *CircuitBreaker) enter(ctx context.Context, hedge bool) (uint64, func(), error) {
+	c.mu.Lock()
+	err := c.check(hedge)
+	if err != nil && !hedge {
+		c.addAnalyticsCircuitRejectionCount()
+	}
+	c.unlock()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	giveBackToken := func() {}
+	if c.RateLimiter != nil {
+		if err := c.RateLimiter.take(ctx, !hedge); err != nil {
+			if err == ErrRateLimited && !hedge {
+				c.mu.Lock()
+				c.addAnalyticsRateLimitRejectionCount()
+				c.mu.Unlock()
+			}
+			return 0, nil, err
+		}
+		giveBackToken = c.RateLimiter.cancel
+	}
+
+	release, err := c.enterBulkhead(ctx, !hedge)
+	if err != nil {
+		giveBackToken()
+		if err == ErrBulkheadFull && !hedge {
+			c.mu.Lock()
+			c.addAnalyticsBulkheadRejectionCount()
+			c.mu.Unlock()
+		}
+		return 0, nil, err
+	}
+
+	if c.AdaptiveLimiter != nil {
+		releaseLimit, err := c.AdaptiveLimiter.acquire()
+		if err != nil {
+			release()
+			giveBackToken()
+			if !hedge {
+				c.mu.Lock()
+				c.addAnalyticsConcurrencyLimitRejectionCount()
+				c.mu.Unlock()
+			}
+			return 0, nil, err
+		}
+		releaseBulkhead := release
+		release = func() {
+			releaseLimit()
+			releaseBulkhead()
+		}
+	}
+
+	generation, err := c.admit(hedge)
+	if err != nil {
+		release()
+		giveBackToken()
+		if !hedge {
+			c.mu.Lock()
+			c.addAnalyticsCircuitRejectionCount()
+			c.mu.Unlock()
+		}
+		return 0, nil, err
+	}
+
+	return generation, release, nil
+}
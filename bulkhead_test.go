@@ -0,0 +1,162 @@
+package cutout
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fill up the bulkhead of the circuit breaker with calls blocked on the service, wait for them with the wait group
+func fillBulkhead(t *testing.T, cb *CircuitBreaker, ts *testService, wg *sync.WaitGroup) {
+	for i := 0; i < cb.MaxConcurrentCalls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cb.Call(ts.request()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	for atomic.LoadInt32(&ts.hits) < int32(cb.MaxConcurrentCalls) {
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBulkheadRejectsExcessCalls(t *testing.T) {
+	cb := NewCircuitBreaker(3, time.Second)
+	cb.MaxConcurrentCalls = 2
+	cb.InitAnalytics()
+
+	ts := newTestService(http.StatusOK, true)
+	defer ts.Close()
+
+	var wg sync.WaitGroup
+	fillBulkhead(t, cb, ts, &wg)
+
+	if _, err := cb.Call(ts.request()); !errors.Is(err, ErrBulkheadFull) {
+		t.Errorf("Expected %v, got %v", ErrBulkheadFull, err)
+	}
+	resp, err := cb.Call(ts.request(), cacheFallback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.BodyString != "cache" {
+		t.Errorf("Expected the fallback response, got %q", resp.BodyString)
+	}
+
+	ts.release()
+	wg.Wait()
+
+	if hits := atomic.LoadInt32(&ts.hits); hits != 2 {
+		t.Errorf("Expected %d hits, got %d", 2, hits)
+	}
+	a := cb.GetAnalytics()
+	if a.BulkheadRejections != 2 {
+		t.Errorf("Expected %d rejections, got %d", 2, a.BulkheadRejections)
+	}
+	if a.TotalFailures != 0 || cb.FailCount() != 0 {
+		t.Errorf("Rejections should not count as failures, got %d failures", a.TotalFailures)
+	}
+
+	if _, err := cb.Call(ts.request()); err != nil { // the slots are free again
+		t.Error(err)
+	}
+}
+
+func TestBulkheadRejectionBeforeAnyRequest(t *testing.T) {
+	cb := NewCircuitBreaker(3, time.Second)
+	cb.MaxConcurrentCalls = 1
+	cb.InitAnalytics()
+
+	ts := newTestService(http.StatusOK, true)
+	defer ts.Close()
+
+	var wg sync.WaitGroup
+	fillBulkhead(t, cb, ts, &wg)
+	defer wg.Wait()
+	defer ts.release()
+
+	if _, err := cb.Call(ts.request()); !errors.Is(err, ErrBulkheadFull) {
+		t.Errorf("Expected %v, got %v", ErrBulkheadFull, err)
+	}
+
+	a := cb.GetAnalytics()
+	if a.SuccessRate != 0 || a.FailureRate != 0 {
+		t.Errorf("Expected no rates before any request was sent, got %v & %v", a.SuccessRate, a.FailureRate)
+	}
+	if _, err := json.Marshal(a); err != nil {
+		t.Errorf("The analytics should be marshalled, got %v", err)
+	}
+}
+
+func TestBulkheadQueue(t *testing.T) {
+	cb := NewCircuitBreaker(3, time.Second)
+	cb.MaxConcurrentCalls = 1
+	cb.MaxWaitingCalls = 1
+	cb.MaxWaitDuration = time.Second
+
+	ts := newTestService(http.StatusOK, true)
+	defer ts.Close()
+
+	var wg sync.WaitGroup
+	fillBulkhead(t, cb, ts, &wg)
+
+	queued := make(chan error)
+	go func() {
+		_, err := cb.Call(ts.request())
+		queued <- err
+	}()
+	for {
+		cb.mu.Lock()
+		waiting := cb.waitingCalls
+		cb.mu.Unlock()
+		if waiting == 1 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if _, err := cb.Call(ts.request()); !errors.Is(err, ErrBulkheadFull) { // the queue is full
+		t.Errorf("Expected %v, got %v", ErrBulkheadFull, err)
+	}
+
+	ts.release()
+	if err := <-queued; err != nil {
+		t.Errorf("The queued call should have gone through, got %v", err)
+	}
+	wg.Wait()
+}
+
+func TestBulkheadWaitTimeout(t *testing.T) {
+	cb := NewCircuitBreaker(3, time.Second)
+	cb.MaxConcurrentCalls = 1
+	cb.MaxWaitingCalls = 1
+	cb.MaxWaitDuration = 50 * time.Millisecond
+
+	ts := newTestService(http.StatusOK, true)
+	defer ts.Close()
+
+	var wg sync.WaitGroup
+	fillBulkhead(t, cb, ts, &wg)
+	defer wg.Wait()
+	defer ts.release()
+
+	start := time.Now()
+	if _, err := cb.Call(ts.request()); !errors.Is(err, ErrBulkheadFull) {
+		t.Errorf("Expected %v, got %v", ErrBulkheadFull, err)
+	}
+	if elapsed := time.Since(start); elapsed < cb.MaxWaitDuration {
+		t.Errorf("The call should have waited for %v, waited for %v", cb.MaxWaitDuration, elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := cb.CallContext(ctx, ts.request(), cacheFallback); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
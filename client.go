@@ -0,0 +1,24 @@
+package cutout
+
+import (
+	"net/http"
+	"time"
+)
+
+// DefaultClient is the http client used by the circuit breakers with no Client of their own. It is shared by all of
+// them, so that the connections are pooled and reused across the calls.
+var DefaultClient = &http.Client{Transport: newDefaultTransport()}
+
+// a transport like the http.DefaultTransport, but keeping more idle connections per host as a circuit breaker usually
+// calls a single service over and over. A fresh transport is used if the http.DefaultTransport was replaced by something
+// else, e.g, a wrapper installed by an instrumentation library.
+func newDefaultTransport() *http.Transport {
+	t := &http.Transport{}
+	if dt, ok := http.DefaultTransport.(*http.Transport); ok {
+		t = dt.Clone()
+	}
+	t.MaxIdleConns = 100
+	t.MaxIdleConnsPerHost = 100
+	t.IdleConnTimeout = 90 * time.Second
+	return t
+}
@@ -0,0 +1,72 @@
+package cutout
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countingTransport struct {
+	requests int32
+}
+
+func (ct *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&ct.requests, 1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestCircuitBreakerClient(t *testing.T) {
+	ts := newTestService(http.StatusOK, false)
+	defer ts.Close()
+
+	ct := &countingTransport{}
+	cb := NewCircuitBreaker(5, time.Minute)
+	cb.Client = &http.Client{Transport: ct}
+
+	cb.Call(ts.request())
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	cb.CallWithCustomRequest(req, nil)
+
+	if atomic.LoadInt32(&ct.requests) != 2 {
+		t.Errorf("The calls should have gone through the client of the circuit breaker, got %d requests", ct.requests)
+	}
+}
+
+func TestDefaultClientReusesConnections(t *testing.T) {
+	var conns int32
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"message":"PONG!"}`))
+	}))
+	ts.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	cb := NewCircuitBreaker(5, time.Minute)
+	req := &Request{URL: ts.URL, Method: http.MethodGet, TimeOut: time.Second}
+	for i := 0; i < 10; i++ {
+		if _, err := cb.Call(req); err != nil {
+			t.Fatalf("Call:%d, unexpected error: %v", i+1, err)
+		}
+	}
+
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Errorf("The connection should have been reused, got %d connections", n)
+	}
+}
+
+func TestDefaultTransportReplaced(t *testing.T) {
+	defaultTransport := http.DefaultTransport
+	http.DefaultTransport = &countingTransport{}
+	defer func() { http.DefaultTransport = defaultTransport }()
+
+	if tr := newDefaultTransport(); tr == nil || tr.MaxIdleConnsPerHost != 100 {
+		t.Errorf("Expected a fresh transport keeping %d idle connections per host, got %v", 100, tr)
+	}
+}
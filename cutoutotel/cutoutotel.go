@@ -0,0 +1,192 @@
+// Package cutoutotel instruments the cutout circuit breakers with OpenTelemetry
+//
+// Every call made with Call, CallWithCustomRequest or their context variants gets a span, the trace context is
+// propagated to the service through the headers of the outgoing request, and the calls are recorded in the
+// cutout.calls counter & the cutout.call.duration histogram.
+//
+// The package is a module of its own, pinning the OpenTelemetry version it is built against, so that the cutout package
+// itself doesn't depend on OpenTelemetry.
+//
+// Example:
+//
+//  cb := cutout.NewCircuitBreaker(10, 15*time.Second)
+//  cb.Name = "payments"
+//  cb.Instrumentation = cutoutotel.New()
+//
+//  resp, err := cb.CallContext(r.Context(), &req, theFallbackFunc) // a child span of the span of r
+package cutoutotel
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Anondo/cutout"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the name of the instrumentation scope of the tracer & the meter
+const ScopeName = "github.com/Anondo/cutout/cutoutotel"
+
+// the attributes of the spans & the metrics
+const (
+	BreakerKey       = attribute.Key("cutout.breaker")
+	StateKey         = attribute.Key("cutout.state")
+	AttemptsKey      = attribute.Key("cutout.attempts")
+	FallbackLevelKey = attribute.Key("cutout.fallback.level")
+	OutcomeKey       = attribute.Key("cutout.outcome")
+)
+
+// the outcomes of a call
+const (
+	OutcomeSuccess  = "success"
+	OutcomeFallback = "fallback"
+	OutcomeError    = "error"
+)
+
+// Option configures the instrumentation
+type Option func(*config)
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	propagator     propagation.TextMapPropagator
+}
+
+// WithTracerProvider creates the spans with the given provider, the global one if not provided
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tp
+	}
+}
+
+// WithMeterProvider records the metrics with the given provider, the global one if not provided
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) {
+		c.meterProvider = mp
+	}
+}
+
+// WithPropagator propagates the context with the given propagator, the global one if not provided
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(c *config) {
+		c.propagator = p
+	}
+}
+
+// Instrumentation is the OpenTelemetry implementation of cutout.Instrumentation, it can be shared by many circuit
+// breakers
+type Instrumentation struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+	calls      metric.Int64Counter
+	duration   metric.Float64Histogram
+}
+
+var _ cutout.Instrumentation = (*Instrumentation)(nil)
+
+// New creates a new instrumentation
+func New(opts ...Option) *Instrumentation {
+	cfg := config{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.tracerProvider == nil {
+		cfg.tracerProvider = otel.GetTracerProvider()
+	}
+	if cfg.meterProvider == nil {
+		cfg.meterProvider = otel.GetMeterProvider()
+	}
+	if cfg.propagator == nil {
+		cfg.propagator = otel.GetTextMapPropagator()
+	}
+
+	meter := cfg.meterProvider.Meter(ScopeName)
+	calls, err := meter.Int64Counter("cutout.calls",
+		metric.WithDescription("The calls made through the circuit breaker."),
+		metric.WithUnit("{call}"))
+	if err != nil {
+		otel.Handle(err)
+	}
+	duration, err := meter.Float64Histogram("cutout.call.duration",
+		metric.WithDescription("The duration of the calls made through the circuit breaker."),
+		metric.WithUnit("s"))
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	return &Instrumentation{
+		tracer:     cfg.tracerProvider.Tracer(ScopeName),
+		propagator: cfg.propagator,
+		calls:      calls,
+		duration:   duration,
+	}
+}
+
+type startKey struct{}
+
+// StartCall starts the span of the call
+func (i *Instrumentation) StartCall(ctx context.Context, info *cutout.CallInfo) context.Context {
+	name := "cutout " + info.Method
+	if info.Breaker != "" {
+		name = info.Breaker + " " + info.Method
+	}
+
+	ctx, _ = i.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			BreakerKey.String(info.Breaker),
+			attribute.String("http.request.method", info.Method),
+			attribute.String("url.full", info.Name),
+		))
+
+	return context.WithValue(ctx, startKey{}, time.Now())
+}
+
+// Inject adds the trace context to the headers of the outgoing request
+func (i *Instrumentation) Inject(ctx context.Context, header http.Header) {
+	i.propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// EndCall ends the span of the call and records it in the metrics
+func (i *Instrumentation) EndCall(ctx context.Context, info *cutout.CallInfo) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(
+		StateKey.String(info.State),
+		AttemptsKey.Int(info.Attempts),
+		FallbackLevelKey.Int(info.FallbackLevel),
+	)
+	if info.StatusCode != 0 {
+		span.SetAttributes(attribute.Int("http.response.status_code", info.StatusCode))
+	}
+	if info.Err != nil {
+		span.RecordError(info.Err)
+		span.SetStatus(codes.Error, info.Err.Error())
+	}
+	span.End()
+
+	attrs := metric.WithAttributes(BreakerKey.String(info.Breaker), OutcomeKey.String(outcome(info)))
+	if i.calls != nil {
+		i.calls.Add(ctx, 1, attrs)
+	}
+	if start, ok := ctx.Value(startKey{}).(time.Time); ok && i.duration != nil {
+		i.duration.Record(ctx, time.Since(start).Seconds(), attrs)
+	}
+}
+
+// the outcome of a call
+func outcome(info *cutout.CallInfo) string {
+	switch {
+	case info.Err != nil:
+		return OutcomeError
+	case info.FallbackLevel > 0:
+		return OutcomeFallback
+	default:
+		return OutcomeSuccess
+	}
+}
@@ -0,0 +1,173 @@
+package cutoutotel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Anondo/cutout"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func setup(t *testing.T) (*cutout.CircuitBreaker, *tracetest.InMemoryExporter, *sdkmetric.ManualReader,
+	*sdktrace.TracerProvider) {
+	spans := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	t.Cleanup(func() {
+		tp.Shutdown(context.Background())
+		mp.Shutdown(context.Background())
+	})
+
+	cb := cutout.NewCircuitBreaker(1, time.Minute)
+	cb.Name = "payments"
+	cb.Instrumentation = New(
+		WithTracerProvider(tp),
+		WithMeterProvider(mp),
+		WithPropagator(propagation.TraceContext{}),
+	)
+	return cb, spans, reader, tp
+}
+
+func attr(attrs []attribute.KeyValue, key attribute.Key) attribute.Value {
+	for _, kv := range attrs {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestSpansAndPropagation(t *testing.T) {
+	traceparents := make(chan string, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents <- r.Header.Get("traceparent")
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer ts.Close()
+
+	cb, spans, _, tp := setup(t)
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "handler")
+	req := &cutout.Request{URL: ts.URL, Method: http.MethodGet, TimeOut: time.Second}
+	if _, err := cb.CallContext(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	got := spans.GetSpans()
+	if len(got) != 2 {
+		t.Fatalf("Expected %d spans, got %d", 2, len(got))
+	}
+	span := got[0]
+	if span.Name != "payments GET" || span.SpanKind != trace.SpanKindClient {
+		t.Errorf("Invalid span: %s(%v)", span.Name, span.SpanKind)
+	}
+	if span.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Error("The span of the call should be a child of the span of the caller")
+	}
+	if tp := <-traceparents; tp != "00-"+span.SpanContext.TraceID().String()+"-"+span.SpanContext.SpanID().String()+"-01" {
+		t.Errorf("The trace context of the call should be propagated, got %q", tp)
+	}
+	for key, want := range map[attribute.Key]attribute.Value{
+		BreakerKey:                  attribute.StringValue("payments"),
+		StateKey:                    attribute.StringValue(cutout.ClosedState),
+		AttemptsKey:                 attribute.IntValue(1),
+		FallbackLevelKey:            attribute.IntValue(0),
+		"http.request.method":       attribute.StringValue(http.MethodGet),
+		"http.response.status_code": attribute.IntValue(http.StatusOK),
+	} {
+		if v := attr(span.Attributes, key); v != want {
+			t.Errorf("Expected the attribute %s to be %v, got %v", key, want.Emit(), v.Emit())
+		}
+	}
+
+	spans.Reset()
+	custom, _ := http.NewRequest(http.MethodGet, ts.URL+"/fail", nil)
+	if _, err := cb.CallWithCustomRequest(custom, nil); err == nil {
+		t.Fatal("Expected the call to fail")
+	}
+	span = spans.GetSpans()[0]
+	if tp := <-traceparents; tp == "" {
+		t.Error("The trace context of the custom request should be propagated")
+	}
+	if span.Status.Code != codes.Error ||
+		attr(span.Attributes, "http.response.status_code") != attribute.IntValue(http.StatusBadGateway) {
+		t.Errorf("Invalid span of the failed call: %v, %v", span.Status, span.Attributes)
+	}
+	if attr(span.Attributes, StateKey) != attribute.StringValue(cutout.ClosedState) {
+		t.Errorf("Invalid state: %v", attr(span.Attributes, StateKey).Emit())
+	}
+
+	spans.Reset()
+	_, err := cb.Call(req, func() (*cutout.Response, error) {
+		return &cutout.Response{BodyString: "cache"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	span = spans.GetSpans()[0]
+	if attr(span.Attributes, FallbackLevelKey) != attribute.IntValue(1) ||
+		attr(span.Attributes, StateKey) != attribute.StringValue(cutout.OpenState) {
+		t.Errorf("Invalid span of the fallback call: %v", span.Attributes)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	cb, _, reader, _ := setup(t)
+	req := &cutout.Request{URL: ts.URL, Method: http.MethodGet, TimeOut: time.Second}
+	for i := 0; i < 3; i++ {
+		if _, err := cb.Call(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	if len(rm.ScopeMetrics) != 1 || rm.ScopeMetrics[0].Scope.Name != ScopeName {
+		t.Fatalf("Expected the metrics of the scope %s, got %v", ScopeName, rm.ScopeMetrics)
+	}
+
+	found := 0
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		switch data := m.Data.(type) {
+		case metricdata.Sum[int64]:
+			found++
+			if m.Name != "cutout.calls" || len(data.DataPoints) != 1 || data.DataPoints[0].Value != 3 {
+				t.Errorf("Invalid calls: %s %v", m.Name, data.DataPoints)
+			}
+			dp := data.DataPoints[0]
+			if v, _ := dp.Attributes.Value(OutcomeKey); v.AsString() != OutcomeSuccess {
+				t.Errorf("Expected the outcome %s, got %s", OutcomeSuccess, v.AsString())
+			}
+			if v, _ := dp.Attributes.Value(BreakerKey); v.AsString() != "payments" {
+				t.Errorf("Expected the breaker payments, got %s", v.AsString())
+			}
+		case metricdata.Histogram[float64]:
+			found++
+			if m.Name != "cutout.call.duration" || len(data.DataPoints) != 1 || data.DataPoints[0].Count != 3 {
+				t.Errorf("Invalid duration: %s %v", m.Name, data.DataPoints)
+			}
+		}
+	}
+	if found != 2 {
+		t.Errorf("Expected %d metrics, got %d", 2, found)
+	}
+}
@@ -9,6 +9,8 @@
 //
 // 3. Event channel to capture events like State change or failure detection
 //
+// 4. Generic Execute function to protect any operation, like database queries or gRPC calls, not just http calls
+//
 //Here is a basic example:
 //  package main
 //
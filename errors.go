@@ -0,0 +1,104 @@
+package cutout
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Errors returned by the circuit breaker
+var (
+	// ErrCircuitOpen is returned when the circuit is open and there are no fallbacks to serve the call
+	ErrCircuitOpen = errors.New("cutout: circuit is open")
+	// ErrTooManyHalfOpenRequests is returned when the circuit is half open, the trial requests are all taken and there
+	// are no fallbacks to serve the call
+	ErrTooManyHalfOpenRequests = errors.New("cutout: too many requests in the half open state")
+	// ErrAllFallbacksFailed is returned when every fallback failed to serve the call, the error returned wraps the
+	// errors of each fallback as well
+	ErrAllFallbacksFailed = errors.New("cutout: all fallbacks failed")
+	// ErrBulkheadFull is returned when the circuit breaker already has as many calls in flight as it allows, no slot
+	// has freed up in time and there are no fallbacks to serve the call
+	ErrBulkheadFull = errors.New("cutout: too many concurrent calls")
+	// ErrRateLimited is returned when the rate limiter of the circuit breaker has no room for the call and there are no
+	// fallbacks to serve it
+	ErrRateLimited = errors.New("cutout: rate limit exceeded")
+	// ErrConcurrencyLimitExceeded is returned when the adaptive limiter of the circuit breaker has no room for the call
+	// and there are no fallbacks to serve it
+	ErrConcurrencyLimitExceeded = errors.New("cutout: concurrency limit exceeded")
+
+	// a hedged copy of a call is not sent unless the circuit is closed
+	errHedgeSuppressed = errors.New("cutout: hedge suppressed")
+	// the copies of a hedged call still in flight are cancelled with this cause once another copy succeeds
+	errHedgeLost = errors.New("cutout: another copy of the call succeeded")
+)
+
+// the length the body of a response is truncated to in an HTTPStatusError
+const maxErrorBodyLength = 256
+
+// HTTPStatusError is returned when a service responds with a status code that is not allowed
+//
+// Body holds the response body truncated to a few hundred bytes, the full body is still available in the Response.
+//
+// Example:
+//
+//  var statusErr *cutout.HTTPStatusError
+//  if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusTooManyRequests {
+// 	 // slow down
+//  }
+type HTTPStatusError struct {
+	StatusCode int
+	Method     string
+	URL        string
+	Body       string
+	Response   *Response
+}
+
+func newHTTPStatusError(resp *Response) *HTTPStatusError {
+	e := &HTTPStatusError{
+		StatusCode: resp.StatusCode,
+		Body:       truncate(resp.BodyString, maxErrorBodyLength),
+		Response:   resp,
+	}
+	if resp.Request != nil {
+		e.Method = resp.Request.Method
+		e.URL = resp.Request.URL.String()
+	}
+	return e
+}
+
+func (e *HTTPStatusError) Error() string {
+	status := strconv.Itoa(e.StatusCode)
+	if text := http.StatusText(e.StatusCode); text != "" {
+		status += " " + text
+	}
+
+	msg := fmt.Sprintf("cutout: %s %s responded with %s", e.Method, e.URL, status)
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+	return msg
+}
+
+// truncate s to at most n bytes without splitting a character
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "") + "..."
+}
+
+// noResponseError marks an error of a request failing to get any response at all, the circuit breaker backs off the
+// timeout of the next requests on such errors
+type noResponseError struct {
+	err error
+}
+
+func (e *noResponseError) Error() string {
+	return e.err.Error()
+}
+
+func (e *noResponseError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,103 @@
+package cutout
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestHTTPStatusError(t *testing.T) {
+	cb := NewCircuitBreaker(5, time.Minute)
+
+	ts := newTestService(http.StatusServiceUnavailable, false)
+	defer ts.Close()
+
+	resp, err := cb.Call(ts.request())
+
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("Expected an *HTTPStatusError, got %v", err)
+	}
+	if statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Incorrent status code received, wanted %d got %d", http.StatusServiceUnavailable, statusErr.StatusCode)
+	}
+	if resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Error("The response should still be returned along with the error")
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	if _, err := cb.CallWithCustomRequest(req, nil); !errors.As(err, &statusErr) {
+		t.Errorf("Expected an *HTTPStatusError, got %v", err)
+	}
+}
+
+func TestErrAllFallbacksFailed(t *testing.T) {
+	cb := NewCircuitBreaker(1, time.Minute)
+	tripCircuit(t, cb)
+
+	errCache := errors.New("cache miss")
+	errReplica := errors.New("replica down")
+
+	_, err := cb.Call(&Request{}, func() (*Response, error) {
+		return nil, errCache
+	}, func() (*Response, error) {
+		return nil, errReplica
+	})
+
+	for _, want := range []error{ErrAllFallbacksFailed, errCache, errReplica} {
+		if !errors.Is(err, want) {
+			t.Errorf("Expected the error to wrap %v, got %v", want, err)
+		}
+	}
+
+	resp, err := cb.Call(&Request{}, func() (*Response, error) {
+		return nil, errCache
+	}, cacheFallback)
+	if err != nil || resp.BodyString != "cache" {
+		t.Errorf("The second level fallback should have served the call, got %v", err)
+	}
+}
+
+func TestHTTPStatusErrorDetails(t *testing.T) {
+	cb := NewCircuitBreaker(5, time.Minute)
+	cb.InitAnalytics()
+
+	body := strings.Repeat("é", 1000)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		io.WriteString(w, body)
+	}))
+	defer ts.Close()
+
+	req := &Request{URL: ts.URL + "/users", Method: http.MethodGet, TimeOut: time.Second}
+	_, err := cb.Call(req)
+
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("Expected an *HTTPStatusError, got %v", err)
+	}
+	if statusErr.Method != http.MethodGet || statusErr.URL != req.URL {
+		t.Errorf("Invalid request details, wanted %s %s got %s %s", http.MethodGet, req.URL, statusErr.Method, statusErr.URL)
+	}
+	if len(statusErr.Body) > maxErrorBodyLength+len("...") || !utf8.ValidString(statusErr.Body) {
+		t.Errorf("The body should have been truncated to a valid string, got %d bytes", len(statusErr.Body))
+	}
+	if statusErr.Response == nil || statusErr.Response.BodyString != body {
+		t.Error("The full body should still be available in the response")
+	}
+
+	want := "cutout: GET " + req.URL + " responded with 502 Bad Gateway: " + statusErr.Body
+	if statusErr.Error() != want {
+		t.Errorf("Invalid error message, wanted %q got %q", want, statusErr.Error())
+	}
+
+	failures := cb.GetAnalytics().Failures
+	if len(failures) != 1 || failures[0].Message != want || failures[0].StatusCode != http.StatusBadGateway {
+		t.Errorf("Invalid analytics failure, got %+v", failures)
+	}
+}
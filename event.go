@@ -1,11 +1,23 @@
 package cutout
 
+import "time"
+
 // Events
 const (
-	StateChangeEvent = "STATE_CHANGE"
-	FailureEvent     = "FAILURE"
+	StateChangeEvent     = "STATE_CHANGE"
+	FailureEvent         = "FAILURE"
+	HalfOpenFailureEvent = "HALF_OPEN_FAILURE" // a trial request failed in the half open state, reopening the circuit
+	SlowCallEvent        = "SLOW_CALL"         // a call took longer than the slow call threshold
 )
 
+// Event holds the details of an event fired by a circuit breaker
+type Event struct {
+	Type       string    `json:"type"`
+	Breaker    string    `json:"breaker"`
+	State      string    `json:"state"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
 // InitEvent initializes the circuit breaker events
 // NOTE: the parameter must be a buffered channel
 //
@@ -28,18 +40,96 @@ const (
 // 			 log.Println("Current state:", cb.State())
 // 		 case cutout.FailureEvent:
 // 			 log.Println("Failure occured")
+// 		 case cutout.HalfOpenFailureEvent:
+// 			 log.Println("Trial request failed, circuit opened again")
 // 		 }
 // 	 }
 // 	 log.Println("Done with the waiting")
 //  }()
 func (c *CircuitBreaker) InitEvent(e chan string) {
 	if cap(e) > 0 {
+		c.mu.Lock()
 		c.events = e
+		c.mu.Unlock()
 	}
 }
 
+// InitEventStream initializes a channel receiving the events along with their details, like the name of the circuit
+// breaker and its state when the event occurred, handy for a single listener watching many circuit breakers
+// NOTE: the parameter must be a buffered channel
+//
+// Example:
+//
+//  events := make(chan cutout.Event, 10)
+//
+//  for _, cb := range registry.List() {
+// 	 cb.InitEventStream(events)
+//  }
+//
+//  go func() {
+// 	 for e := range events {
+// 		 log.Printf("%s: %s event, current state: %s", e.Breaker, e.Type, e.State)
+// 	 }
+//  }()
+func (c *CircuitBreaker) InitEventStream(e chan Event) {
+	if cap(e) > 0 {
+		c.mu.Lock()
+		c.eventStream = e
+		c.mu.Unlock()
+	}
+}
+
+// whether anyone is listening to the events
+func (c *CircuitBreaker) hasListeners() bool {
+	return cap(c.events) > 0 || cap(c.eventStream) > 0
+}
+
+// queue an event to be delivered once the circuit breaker lock is released, must be called with the lock held
 func (c *CircuitBreaker) fireEvent(event string) {
-	if cap(c.events) > 0 {
-		c.events <- event
+	if c.hasListeners() {
+		c.eventMu.Lock()
+		c.eventQueue = append(c.eventQueue, Event{
+			Type:       event,
+			Breaker:    c.Name,
+			State:      c.state,
+			OccurredAt: time.Now(),
+		})
+		c.eventMu.Unlock()
+	}
+}
+
+// unlock releases the circuit breaker lock and then delivers the queued events, so that a listener calling back
+// into the circuit breaker(e.g, State()) can never deadlock it
+func (c *CircuitBreaker) unlock() {
+	hasListeners := c.hasListeners()
+	events, eventStream := c.events, c.eventStream
+	c.mu.Unlock()
+
+	if hasListeners {
+		c.deliverEvents(events, eventStream)
+	}
+}
+
+// deliver the queued events in the order they were fired
+func (c *CircuitBreaker) deliverEvents(events chan string, eventStream chan Event) {
+	c.deliverMu.Lock()
+	defer c.deliverMu.Unlock()
+
+	for {
+		c.eventMu.Lock()
+		if len(c.eventQueue) == 0 {
+			c.eventMu.Unlock()
+			return
+		}
+		event := c.eventQueue[0]
+		c.eventQueue = c.eventQueue[1:]
+		c.eventMu.Unlock()
+
+		if cap(events) > 0 {
+			events <- event.Type
+		}
+		if cap(eventStream) > 0 {
+			eventStream <- event
+		}
 	}
 }
@@ -10,7 +10,7 @@ func pingHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Add("Content-Type", "Application/json")
 
-	pr, err := getPingFromService()
+	pr, err := getPingFromService(r.Context())
 
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -53,7 +54,7 @@ func fallBackFunc() (*cutout.Response, error) {
 	}, nil
 }
 
-func getPingFromService() (*pingResponse, error) {
+func getPingFromService(ctx context.Context) (*pingResponse, error) {
 	pingRequest := &cutout.Request{
 		URL:           "http://thisisanexampleurl.com",
 		Method:        http.MethodGet,
@@ -63,9 +64,10 @@ func getPingFromService() (*pingResponse, error) {
 		BackOff: func(t time.Duration) time.Duration {
 			return time.Duration(int(t/time.Second)*5) * time.Second
 		},
+		MaxTimeOut: 30 * time.Second,
 	}
 
-	resp, err := cb.Call(pingRequest, fallBackFunc)
+	resp, err := cb.CallContext(ctx, pingRequest, fallBackFunc)
 
 	if err != nil {
 		return nil, err
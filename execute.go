@@ -0,0 +1,108 @@
+package cutout
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Execute runs any operation, like a database query, a gRPC call or publishing to a message broker, using the circuit
+// breaker design. The operation goes through the same states, events and analytics as the http calls do.
+//
+// Parameters:
+//
+// 1. context.Context -------> The context passed on to the operation
+//
+// 2. *cutout.CircuitBreaker -------> The circuit breaker protecting the operation
+//
+// 3. func(context.Context) (T, error) -------> The operation, an error returned from it is counted as a failure
+//
+// 4. ...func() (T, error) -----> one or many fallback functions returning the same type as the operation
+//
+// If the call is not let through and no fallback functions are provided, the reason is returned(ErrCircuitOpen,
+// ErrTooManyHalfOpenRequests, ErrBulkheadFull, ErrRateLimited or ErrConcurrencyLimitExceeded), if all the fallback
+// functions fail, ErrAllFallbacksFailed is returned.
+// An error returned after the context got cancelled or exceeded its deadline is not counted as a failure, the caller
+// has given up on the call.
+//
+// Example:
+//
+//  user, err := cutout.Execute(ctx, cb, func(ctx context.Context) (*User, error) {
+// 	 return db.FindUser(ctx, id)
+//  }, func() (*User, error) {
+// 	 return cache.User(id)
+//  })
+func Execute[T any](ctx context.Context, c *CircuitBreaker, operation func(context.Context) (T, error),
+	fallbackFuncs ...func() (T, error)) (T, error) {
+	return execute(ctx, c, operation, nil, fallbackFuncs)
+}
+
+// run an operation through the circuit as a call of its own, describe fills in the request record of the call if
+// provided
+func execute[T any](ctx context.Context, c *CircuitBreaker, operation func(context.Context) (T, error),
+	describe func(*RequestRecord, T), fallbackFuncs []func() (T, error)) (T, error) {
+	result, err := executeAttempt(ctx, c, operation, describe, fallbackFuncs)
+	c.countCall()
+	return result, err
+}
+
+// whether the caller has given up on a call made within ctx. The deadline of a round trip is the timeout of the
+// http.Client, which the service failed to respond within, only the cancellation of its request is the caller giving up.
+func callerGaveUp(ctx context.Context) bool {
+	if roundTrip, _ := ctx.Value(roundTripKey{}).(bool); roundTrip {
+		return errors.Is(ctx.Err(), context.Canceled)
+	}
+	return ctx.Err() != nil
+}
+
+// run an operation through the circuit as one attempt of a call, the call is counted by the caller
+func executeAttempt[T any](ctx context.Context, c *CircuitBreaker, operation func(context.Context) (T, error),
+	describe func(*RequestRecord, T), fallbackFuncs []func() (T, error)) (T, error) {
+	if err := ctx.Err(); err != nil { // the caller has already given up
+		var zero T
+		return zero, err
+	}
+
+	generation, release, err := c.enter(ctx, false)
+	if err != nil {
+		if ctx.Err() != nil { // gave up waiting for the rate limiter or the bulkhead
+			var zero T
+			return zero, err
+		}
+		return fallback(ctx, c, fallbackFuncs, err)
+	}
+	defer release()
+
+	return run(ctx, c, generation, operation, describe)
+}
+
+// count a call made through the circuit breaker, however many attempts it took
+func (c *CircuitBreaker) countCall() {
+	c.mu.Lock()
+	c.addAnalyticsCallCount()
+	c.mu.Unlock()
+}
+
+// run an operation let through by the circuit during the given generation
+func run[T any](ctx context.Context, c *CircuitBreaker, generation uint64, operation func(context.Context) (T, error),
+	describe func(*RequestRecord, T)) (T, error) {
+	reqTimeForAnlcts := time.Now()
+	result, err := operation(ctx)
+
+	rr := RequestRecord{Breaker: c.Name, RequestedAt: reqTimeForAnlcts, Latency: time.Since(reqTimeForAnlcts)}
+	if describe != nil {
+		describe(&rr, result)
+	}
+
+	c.mu.Lock()
+	if err != nil && callerGaveUp(ctx) { // it says nothing about the service
+		c.recordCancellation(generation, errors.Is(context.Cause(ctx), errHedgeLost))
+	} else {
+		c.recordResult(generation, reqTimeForAnlcts, err)
+	}
+	c.updateAnalyticsRequestRecord(rr)
+	c.updateAnalyticsRates()
+	c.unlock()
+
+	return result, err
+}
@@ -0,0 +1,71 @@
+package cutout
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type ctxKey struct{}
+
+func TestExecute(t *testing.T) {
+	cb := NewCircuitBreaker(2, time.Minute)
+	cb.InitAnalytics()
+	event := make(chan string, 10)
+	cb.InitEvent(event)
+
+	errDB := errors.New("connection refused")
+	healthy := true
+	query := func(ctx context.Context) (int, error) {
+		if ctx.Value(ctxKey{}) != "request-scoped" {
+			t.Error("The context should have been passed on to the operation")
+		}
+		if !healthy {
+			return 0, errDB
+		}
+		return 42, nil
+	}
+	cached := func() (int, error) {
+		return 7, nil
+	}
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "request-scoped")
+
+	if n, err := Execute(ctx, cb, query, cached); err != nil || n != 42 {
+		t.Fatalf("Unexpected result, wanted 42 got %d, error: %v", n, err)
+	}
+
+	healthy = false
+	for i := 0; i < cb.FailThreshold; i++ {
+		if _, err := Execute(ctx, cb, query, cached); !errors.Is(err, errDB) {
+			t.Fatalf("Expected %v, got %v", errDB, err)
+		}
+	}
+
+	if n, err := Execute(ctx, cb, query, cached); err != nil || n != 7 {
+		t.Fatalf("The fallback should have served the call, wanted 7 got %d, error: %v", n, err)
+	}
+	if _, err := Execute(ctx, cb, query); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected %v, got %v", ErrCircuitOpen, err)
+	}
+	if state := cb.State(); state != OpenState {
+		t.Errorf("Incorrect state of the circuit received, wanted %s got %s", OpenState, state)
+	}
+
+	anlcts := cb.GetAnalytics()
+	if anlcts.RequestSent != 3 || anlcts.TotalFailures != 2 || anlcts.FallbackCalls != 1 || anlcts.TotalCalls != 5 {
+		t.Errorf("Invalid analytics, request sent:%d, total failures:%d, fallback calls:%d, total calls:%d",
+			anlcts.RequestSent, anlcts.TotalFailures, anlcts.FallbackCalls, anlcts.TotalCalls)
+	}
+
+	failures := 0
+	for len(event) > 0 {
+		if <-event == FailureEvent {
+			failures++
+		}
+	}
+	if failures != 2 {
+		t.Errorf("Invalid number of failure events, wanted:%d, got:%d", 2, failures)
+	}
+}
@@ -1,19 +1,56 @@
 package cutout
 
-func executeFallbacks(fbf []func() (*Response, error)) (*Response, error) {
+import (
+	"context"
+	"errors"
+	"fmt"
+)
 
-	fResp := &Response{}
-	var err error
+// serve a call the circuit didn't let through, cause is returned if there are no fallbacks to serve it
+func fallback[T any](ctx context.Context, c *CircuitBreaker, fbf []func() (T, error), cause error) (T, error) {
+	c.mu.Lock()
+	c.addAnalyticsRejectionCount()
+	if len(fbf) == 0 {
+		c.updateAnalyticsRates()
+		c.unlock()
+		var zero T
+		return zero, cause
+	}
+	c.mu.Unlock()
+
+	resp, level, err := executeFallbacks(fbf)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	if info := callInfoFrom(ctx); info != nil {
+		info.FallbackLevel = level
+	}
+
+	c.mu.Lock()
+	c.addAnalyticsFallbackCount()
+	c.updateAnalyticsRates()
+	c.unlock()
+
+	return resp, nil
+}
+
+// run the fallbacks until one succeeds, returns its response and its level(1 for the first one)
+func executeFallbacks[T any](fbf []func() (T, error)) (T, int, error) {
+
+	var errs []error
 
-	for _, fb := range fbf { //as cutout supports multi-level fallbacks
-		fResp, err = fb()
+	for i, fb := range fbf { //as cutout supports multi-level fallbacks
+		fResp, err := fb()
 
 		if err != nil {
+			errs = append(errs, err)
 			continue // if one fails, try the next one
 		}
 
-		break
+		return fResp, i + 1, nil
 	}
 
-	return fResp, err
+	var zero T
+	return zero, 0, fmt.Errorf("%w: %w", ErrAllFallbacksFailed, errors.Join(errs...))
 }
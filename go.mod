@@ -0,0 +1,3 @@
+module github.com/Anondo/cutout
+
+go 1.20
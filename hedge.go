@@ -0,0 +1,120 @@
+package cutout
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// HedgePolicy configures the hedging of a request, i.e, sending copies of a request that is taking too long and
+// going with whichever copy succeeds first
+//
+// If there is no response Delay after the request was sent, a copy of it is sent, then another one after each Delay
+// up to MaxHedges copies. As soon as one of them succeeds, the rest are cancelled. The cancelled copies are not counted
+// as failures, nor as cancelled calls.
+//
+// Only the requests with the idempotent methods GET, HEAD & OPTIONS are hedged. The copies go through the circuit
+// breaker, recorded in its analytics, but they are only sent while the circuit is closed, never to a recovering service
+// in the half open state, and while the rate limiter and the bulkhead have room for them.
+//
+// Example:
+//
+//  req := cutout.Request{
+// 	 URL:     "http://localhost:9090",
+// 	 Method:  http.MethodGet,
+// 	 TimeOut: 2 * time.Second,
+// 	 Hedge: &cutout.HedgePolicy{
+// 		 Delay:     50 * time.Millisecond,
+// 		 MaxHedges: 2,
+// 	 },
+//  }
+type HedgePolicy struct {
+	Delay     time.Duration
+	MaxHedges int
+}
+
+// whether the request is to be hedged
+func (r *Request) hedged() bool {
+	if r.Hedge == nil || r.Hedge.MaxHedges < 1 {
+		return false
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, "":
+		return true
+	}
+	return false
+}
+
+type hedgeResult struct {
+	resp *Response
+	err  error
+}
+
+// make the request through the circuit, hedging it as the hedge policy of the request says
+func (c *CircuitBreaker) callHedged(ctx context.Context, req *Request, attempt int,
+	fallbackFuncs []func() (*Response, error)) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	generation, release, err := c.enter(ctx, false)
+	if err != nil {
+		if ctx.Err() != nil { // gave up waiting for the rate limiter or the bulkhead
+			return nil, err
+		}
+		return fallback(ctx, c, fallbackFuncs, err)
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	results := make(chan hedgeResult, 1+req.Hedge.MaxHedges)
+	send := func(generation uint64, release func(), hedge bool) {
+		describe := describeHTTPAttempt(req.URL, req.Method, attempt)
+		go func() {
+			defer release()
+			resp, err := run(ctx, c, generation, c.requestOperation(req), func(rr *RequestRecord, resp *Response) {
+				describe(rr, resp)
+				rr.Hedge = hedge
+			})
+			results <- hedgeResult{resp, err}
+		}()
+	}
+
+	send(generation, release, false)
+	inFlight, hedges := 1, 0
+
+	timer := time.NewTimer(req.Hedge.Delay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case res := <-results:
+			inFlight--
+			if res.err == nil || inFlight == 0 {
+				cancel(errHedgeLost) // the slower copies, waiting for them to be recorded
+				for ; inFlight > 0; inFlight-- {
+					<-results
+				}
+				return res.resp, res.err
+			}
+		case <-timer.C:
+			if hedges >= req.Hedge.MaxHedges {
+				continue
+			}
+			generation, release, err := c.enter(ctx, true)
+			if err != nil { // no more copies unless the circuit is closed and there is room for them
+				hedges = req.Hedge.MaxHedges
+				continue
+			}
+			c.mu.Lock()
+			c.addAnalyticsHedgeCount()
+			c.mu.Unlock()
+			send(generation, release, true)
+			inFlight++
+			hedges++
+			timer.Reset(req.Hedge.Delay)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package cutout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newStragglerService starts a service whose first response takes the given latency, the rest are immediate
+func newStragglerService(latency time.Duration) (*httptest.Server, *int32) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			select {
+			case <-time.After(latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	return ts, &hits
+}
+
+func TestHedgeBeatsSlowRequest(t *testing.T) {
+	ts, hits := newStragglerService(2 * time.Second)
+	defer ts.Close()
+
+	cb := NewCircuitBreaker(3, time.Second)
+	cb.InitAnalytics()
+
+	req := &Request{
+		URL:     ts.URL,
+		Method:  http.MethodGet,
+		TimeOut: 5 * time.Second,
+		Hedge:   &HedgePolicy{Delay: 50 * time.Millisecond, MaxHedges: 2},
+	}
+
+	start := time.Now()
+	resp, err := cb.Call(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("The hedge should have answered first, took %v", elapsed)
+	}
+	if h := atomic.LoadInt32(hits); h != 2 {
+		t.Errorf("Expected %d hits, got %d", 2, h)
+	}
+
+	a := cb.GetAnalytics()
+	if a.Hedges != 1 || a.RequestSent != 2 || a.TotalCalls != 1 {
+		t.Errorf("Expected %d hedge, %d requests sent, %d call, got %d, %d, %d", 1, 2, 1, a.Hedges, a.RequestSent,
+			a.TotalCalls)
+	}
+	if a.TotalFailures != 0 {
+		t.Errorf("The cancelled request should not count as a failure, got %d failures", a.TotalFailures)
+	}
+	if a.CancelledCalls != 0 {
+		t.Errorf("The cancelled request should not count as a cancelled call, got %d", a.CancelledCalls)
+	}
+	if cb.FailCount() != 0 {
+		t.Errorf("Expected fail count %d, got %d", 0, cb.FailCount())
+	}
+}
+
+func TestHedgeNotSentForUnsafeMethods(t *testing.T) {
+	ts, hits := newStragglerService(200 * time.Millisecond)
+	defer ts.Close()
+
+	cb := NewCircuitBreaker(3, time.Second)
+	cb.InitAnalytics()
+
+	req := &Request{
+		URL:     ts.URL,
+		Method:  http.MethodPost,
+		TimeOut: 5 * time.Second,
+		Hedge:   &HedgePolicy{Delay: 20 * time.Millisecond, MaxHedges: 2},
+	}
+
+	if _, err := cb.Call(req); err != nil {
+		t.Fatal(err)
+	}
+	if h := atomic.LoadInt32(hits); h != 1 {
+		t.Errorf("Expected %d hit, got %d", 1, h)
+	}
+	if a := cb.GetAnalytics(); a.Hedges != 0 {
+		t.Errorf("Expected %d hedges, got %d", 0, a.Hedges)
+	}
+}
+
+func TestHedgeSuppressedWhileHalfOpen(t *testing.T) {
+	cb := NewCircuitBreaker(1, 50*time.Millisecond)
+	cb.InitAnalytics()
+	tripCircuit(t, cb)
+
+	time.Sleep(2 * cb.HealthCheckPeriod)
+
+	ts, hits := newStragglerService(200 * time.Millisecond)
+	defer ts.Close()
+
+	req := &Request{
+		URL:     ts.URL,
+		Method:  http.MethodGet,
+		TimeOut: 5 * time.Second,
+		Hedge:   &HedgePolicy{Delay: 20 * time.Millisecond, MaxHedges: 2},
+	}
+
+	if _, err := cb.Call(req); err != nil {
+		t.Fatal(err)
+	}
+	if h := atomic.LoadInt32(hits); h != 1 {
+		t.Errorf("Only the trial request should have been sent, got %d hits", h)
+	}
+	if a := cb.GetAnalytics(); a.Hedges != 0 {
+		t.Errorf("Expected %d hedges, got %d", 0, a.Hedges)
+	}
+}
@@ -0,0 +1,175 @@
+package cutout
+
+import (
+	"encoding/json"
+	"math"
+	"math/bits"
+	"time"
+)
+
+// the number of buckets a histogram splits every power of two into, the percentiles are accurate to about 6%
+const (
+	histogramSubBucketBits = 4
+	histogramSubBuckets    = 1 << histogramSubBucketBits
+)
+
+// Histogram holds the distribution of the latencies of the calls, to the microsecond
+//
+// The histograms of many circuit breakers or time periods can be merged into one. Unlike the circuit breaker, a
+// Histogram is not safe for concurrent use, the ones in the analytics snapshots are copies of their own.
+//
+// Example:
+//
+//  latency := cb.GetAnalytics().Latency
+//  log.Println("p99 to the payments api:", latency.P99())
+type Histogram struct {
+	counts []uint64
+	count  uint64
+	sum    time.Duration
+	max    time.Duration
+}
+
+// HistogramBucket holds the number of latencies recorded up to UpperBound, since the previous bucket
+type HistogramBucket struct {
+	UpperBound time.Duration
+	Count      uint64
+}
+
+// the bucket a latency falls into
+func histogramIndex(d time.Duration) int {
+	v := uint64(0)
+	if d > 0 {
+		v = uint64(d / time.Microsecond)
+	}
+	if v < histogramSubBuckets {
+		return int(v)
+	}
+	exp := bits.Len64(v) - histogramSubBucketBits - 1
+	return histogramSubBuckets*(exp+1) + int(v>>exp) - histogramSubBuckets
+}
+
+// the highest latency of a bucket
+func histogramUpperBound(index int) time.Duration {
+	if index < histogramSubBuckets {
+		return time.Duration(index+1) * time.Microsecond
+	}
+	exp := index/histogramSubBuckets - 1
+	m := uint64(index%histogramSubBuckets + histogramSubBuckets)
+	return time.Duration((m+1)<<exp) * time.Microsecond
+}
+
+// Record adds a latency to the histogram
+func (h *Histogram) Record(d time.Duration) {
+	i := histogramIndex(d)
+	if i >= len(h.counts) {
+		h.counts = append(h.counts, make([]uint64, i+1-len(h.counts))...)
+	}
+	h.counts[i]++
+	h.count++
+	h.sum += d
+	if d > h.max {
+		h.max = d
+	}
+}
+
+// Merge adds the latencies of another histogram to the histogram
+func (h *Histogram) Merge(other *Histogram) {
+	if other == nil {
+		return
+	}
+	if len(other.counts) > len(h.counts) {
+		h.counts = append(h.counts, make([]uint64, len(other.counts)-len(h.counts))...)
+	}
+	for i, n := range other.counts {
+		h.counts[i] += n
+	}
+	h.count += other.count
+	h.sum += other.sum
+	if other.max > h.max {
+		h.max = other.max
+	}
+}
+
+// Clone returns a copy of the histogram
+func (h *Histogram) Clone() *Histogram {
+	clone := &Histogram{}
+	clone.Merge(h)
+	return clone
+}
+
+// Count returns the number of latencies recorded
+func (h *Histogram) Count() uint64 {
+	return h.count
+}
+
+// Sum returns the sum of the latencies recorded
+func (h *Histogram) Sum() time.Duration {
+	return h.sum
+}
+
+// Max returns the highest latency recorded
+func (h *Histogram) Max() time.Duration {
+	return h.max
+}
+
+// Quantile returns the latency the given fraction(0 to 1) of the calls took at most, e.g, 0.99 for the 99th percentile
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(q * float64(h.count)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen uint64
+	for i, n := range h.counts {
+		seen += n
+		if seen >= rank {
+			if upper := histogramUpperBound(i); upper < h.max {
+				return upper
+			}
+			return h.max
+		}
+	}
+	return h.max
+}
+
+// P50 returns the median latency
+func (h *Histogram) P50() time.Duration {
+	return h.Quantile(0.5)
+}
+
+// P90 returns the 90th percentile latency
+func (h *Histogram) P90() time.Duration {
+	return h.Quantile(0.9)
+}
+
+// P99 returns the 99th percentile latency
+func (h *Histogram) P99() time.Duration {
+	return h.Quantile(0.99)
+}
+
+// Buckets returns the buckets holding any latencies, from the lowest to the highest
+func (h *Histogram) Buckets() []HistogramBucket {
+	var buckets []HistogramBucket
+	for i, n := range h.counts {
+		if n > 0 {
+			buckets = append(buckets, HistogramBucket{UpperBound: histogramUpperBound(i), Count: n})
+		}
+	}
+	return buckets
+}
+
+// MarshalJSON encodes the summary of the histogram
+func (h *Histogram) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Count uint64        `json:"count"`
+		Sum   time.Duration `json:"sum"`
+		P50   time.Duration `json:"p50"`
+		P90   time.Duration `json:"p90"`
+		P99   time.Duration `json:"p99"`
+		Max   time.Duration `json:"max"`
+	}{h.count, h.sum, h.P50(), h.P90(), h.P99(), h.max})
+}
@@ -0,0 +1,115 @@
+package cutout
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHistogramIndex(t *testing.T) {
+	for _, d := range []time.Duration{
+		0, 5 * time.Microsecond, 16 * time.Microsecond, 17 * time.Microsecond, 999 * time.Microsecond,
+		time.Millisecond, 123 * time.Millisecond, time.Second, time.Minute, time.Hour,
+	} {
+		i := histogramIndex(d)
+		if upper := histogramUpperBound(i); d >= upper || (i > 0 && d < histogramUpperBound(i-1)) {
+			t.Errorf("%v should not fall into the bucket %d ending at %v", d, i, upper)
+		}
+		if i >= histogramSubBuckets {
+			if lower := histogramUpperBound(i - 1); float64(histogramUpperBound(i)-lower)/float64(lower) > 0.07 {
+				t.Errorf("The bucket %d of %v is too wide", i, d)
+			}
+		}
+	}
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	h := &Histogram{}
+	if h.P99() != 0 {
+		t.Errorf("Expected %v for an empty histogram, got %v", 0, h.P99())
+	}
+
+	for i := 1; i <= 100; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+
+	for _, tc := range []struct {
+		quantile time.Duration
+		want     time.Duration
+	}{
+		{h.P50(), 50 * time.Millisecond},
+		{h.P90(), 90 * time.Millisecond},
+		{h.P99(), 99 * time.Millisecond},
+	} {
+		if tc.quantile < tc.want || float64(tc.quantile-tc.want)/float64(tc.want) > 0.07 {
+			t.Errorf("Expected about %v, got %v", tc.want, tc.quantile)
+		}
+	}
+	if h.Quantile(1) != 100*time.Millisecond || h.Max() != 100*time.Millisecond {
+		t.Errorf("Expected the max to be %v, got %v", 100*time.Millisecond, h.Max())
+	}
+	if h.Count() != 100 || h.Sum() != 5050*time.Millisecond {
+		t.Errorf("Invalid count/sum: %d/%v", h.Count(), h.Sum())
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	a, b := &Histogram{}, &Histogram{}
+	for i := 0; i < 90; i++ {
+		a.Record(time.Millisecond)
+	}
+	for i := 0; i < 10; i++ {
+		b.Record(time.Second)
+	}
+
+	merged := a.Clone()
+	merged.Merge(b)
+	if a.Count() != 90 {
+		t.Errorf("The clone should not share the counts, got %d", a.Count())
+	}
+	if merged.Count() != 100 || merged.Max() != time.Second {
+		t.Errorf("Invalid count/max: %d/%v", merged.Count(), merged.Max())
+	}
+	if merged.P90() > 2*time.Millisecond || merged.P99() != time.Second {
+		t.Errorf("Invalid p90/p99: %v/%v", merged.P90(), merged.P99())
+	}
+
+	var total uint64
+	for _, b := range merged.Buckets() {
+		total += b.Count
+	}
+	if len(merged.Buckets()) != 2 || total != 100 {
+		t.Errorf("Invalid buckets: %v", merged.Buckets())
+	}
+}
+
+func TestAnalyticsLatency(t *testing.T) {
+	ts := newTestService(http.StatusOK, false)
+	defer ts.Close()
+	ts.setLatency(20 * time.Millisecond)
+
+	cb := NewCircuitBreaker(3, time.Second)
+	cb.InitAnalytics()
+	for i := 0; i < 3; i++ {
+		if _, err := cb.Call(ts.request()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	anlcts := cb.GetAnalytics()
+	for _, rr := range anlcts.RequestRecords {
+		if rr.Latency < 20*time.Millisecond {
+			t.Errorf("Expected the latency to be at least %v, got %v", 20*time.Millisecond, rr.Latency)
+		}
+	}
+	for _, h := range []*Histogram{anlcts.Latency, anlcts.RollingLatency} {
+		if h.Count() != 3 || h.P50() < 20*time.Millisecond || h.Max() < h.P99() {
+			t.Errorf("Invalid latencies, count: %d, p50: %v, p99: %v, max: %v", h.Count(), h.P50(), h.P99(), h.Max())
+		}
+	}
+
+	if _, err := json.Marshal(anlcts); err != nil {
+		t.Error(err)
+	}
+}
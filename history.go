@@ -0,0 +1,114 @@
+package cutout
+
+import "time"
+
+// AnalyticsOption configures the retention of the failures & request records in the analytics, the counters are
+// lifetime totals regardless
+type AnalyticsOption func(*Analytics)
+
+// WithMaxRecords keeps the last n failures & request records only
+func WithMaxRecords(n int) AnalyticsOption {
+	return func(a *Analytics) {
+		a.maxRecords = n
+	}
+}
+
+// WithMaxAge keeps the failures & request records of the last d only
+func WithMaxAge(d time.Duration) AnalyticsOption {
+	return func(a *Analytics) {
+		a.maxAge = d
+	}
+}
+
+// WithMaxMessageLength truncates the response bodies kept in the request records to n bytes
+func WithMaxMessageLength(n int) AnalyticsOption {
+	return func(a *Analytics) {
+		a.maxMessageLength = n
+	}
+}
+
+// WithoutMessages keeps the response bodies out of the request records
+func WithoutMessages() AnalyticsOption {
+	return func(a *Analytics) {
+		a.omitMessages = true
+	}
+}
+
+// a record kept in a history
+type historyEntry[T any] struct {
+	at     time.Time
+	record T
+}
+
+// history is a ring buffer of records, keeping the last max records(all of them if zero) of the last maxAge(all of
+// them if zero)
+type history[T any] struct {
+	max     int
+	maxAge  time.Duration
+	entries []historyEntry[T]
+	start   int
+	len     int
+}
+
+func newHistory[T any](max int, maxAge time.Duration) *history[T] {
+	return &history[T]{max: max, maxAge: maxAge}
+}
+
+// add a record which occurred at the given time
+func (h *history[T]) add(at time.Time, record T) {
+	h.expire(at)
+
+	entry := historyEntry[T]{at, record}
+	switch {
+	case h.max <= 0 || len(h.entries) < h.max: // still growing, the entries are not wrapped around yet
+		if h.start > len(h.entries)/2 { // let go of the expired ones
+			h.entries = h.list()
+			h.start = 0
+		}
+		h.entries = append(h.entries, entry)
+		h.len++
+	case h.len < h.max:
+		h.entries[(h.start+h.len)%h.max] = entry
+		h.len++
+	default: // overwrite the oldest
+		h.entries[h.start] = entry
+		h.start = (h.start + 1) % h.max
+	}
+}
+
+// drop the records older than the max age
+func (h *history[T]) expire(now time.Time) {
+	if h.maxAge <= 0 {
+		return
+	}
+
+	var zero historyEntry[T]
+	for h.len > 0 && now.Sub(h.entries[h.start].at) > h.maxAge {
+		h.entries[h.start] = zero // let it go
+		h.start = (h.start + 1) % len(h.entries)
+		h.len--
+	}
+	if h.len == 0 {
+		h.entries, h.start = h.entries[:0], 0
+	}
+}
+
+// the entries, oldest first
+func (h *history[T]) list() []historyEntry[T] {
+	entries := make([]historyEntry[T], h.len)
+	for i := range entries {
+		entries[i] = h.entries[(h.start+i)%len(h.entries)]
+	}
+	return entries
+}
+
+// the records, oldest first
+func (h *history[T]) records(now time.Time) []T {
+	h.expire(now)
+
+	records := make([]T, h.len)
+	for i := range records {
+		records[i] = h.entries[(h.start+i)%len(h.entries)].record
+	}
+	return records
+}
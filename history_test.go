@@ -0,0 +1,98 @@
+package cutout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistoryMaxRecords(t *testing.T) {
+	h := newHistory[int](3, 0)
+	now := time.Now()
+
+	for i := 1; i <= 5; i++ {
+		h.add(now, i)
+	}
+	if records := h.records(now); !reflect.DeepEqual(records, []int{3, 4, 5}) {
+		t.Errorf("Expected the last %d records, got %v", 3, records)
+	}
+	if len(h.entries) != 3 {
+		t.Errorf("The ring should not grow past %d entries, got %d", 3, len(h.entries))
+	}
+}
+
+func TestHistoryMaxAge(t *testing.T) {
+	h := newHistory[int](0, time.Minute)
+	now := time.Now()
+
+	for i := 1; i <= 4; i++ {
+		h.add(now.Add(time.Duration(i)*20*time.Second), i)
+	}
+	if records := h.records(now.Add(100 * time.Second)); !reflect.DeepEqual(records, []int{2, 3, 4}) {
+		t.Errorf("Expected the records of the last minute, got %v", records)
+	}
+	if records := h.records(now.Add(time.Hour)); len(records) != 0 {
+		t.Errorf("Expected no records, got %v", records)
+	}
+
+	h.add(now.Add(time.Hour), 5)
+	if records := h.records(now.Add(time.Hour)); !reflect.DeepEqual(records, []int{5}) {
+		t.Errorf("Expected %v, got %v", []int{5}, records)
+	}
+}
+
+func TestHistoryMaxRecordsAndAge(t *testing.T) {
+	h := newHistory[int](3, time.Minute)
+	now := time.Now()
+
+	for i := 1; i <= 5; i++ { // wraps around the ring
+		h.add(now.Add(time.Duration(i)*20*time.Second), i)
+	}
+	h.add(now.Add(150*time.Second), 6)
+	if records := h.records(now.Add(150 * time.Second)); !reflect.DeepEqual(records, []int{5, 6}) {
+		t.Errorf("Expected %v, got %v", []int{5, 6}, records)
+	}
+	if records := h.records(now.Add(200 * time.Second)); !reflect.DeepEqual(records, []int{6}) {
+		t.Errorf("Expected %v, got %v", []int{6}, records)
+	}
+}
+
+func TestAnalyticsRetention(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("a", 100)))
+	}))
+	defer ts.Close()
+
+	req := &Request{URL: ts.URL, Method: http.MethodGet, TimeOut: time.Second}
+
+	cb := NewCircuitBreaker(3, time.Second)
+	cb.InitAnalytics(WithMaxRecords(2), WithMaxMessageLength(10))
+	for i := 0; i < 5; i++ {
+		if _, err := cb.Call(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	anlcts := cb.GetAnalytics()
+	if anlcts.RequestSent != 5 || anlcts.TotalCalls != 5 {
+		t.Errorf("The counters should be lifetime totals, got %d requests sent, %d calls", anlcts.RequestSent,
+			anlcts.TotalCalls)
+	}
+	if len(anlcts.RequestRecords) != 2 {
+		t.Fatalf("Expected %d request records, got %d", 2, len(anlcts.RequestRecords))
+	}
+	if msg := anlcts.RequestRecords[0].Message; msg != strings.Repeat("a", 10)+"..." {
+		t.Errorf("Expected the message to be truncated, got %q", msg)
+	}
+
+	cb.InitAnalytics(WithoutMessages())
+	if _, err := cb.Call(req); err != nil {
+		t.Fatal(err)
+	}
+	if msg := cb.GetAnalytics().RequestRecords[0].Message; msg != "" {
+		t.Errorf("Expected no message, got %q", msg)
+	}
+}
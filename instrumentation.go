@@ -0,0 +1,111 @@
+package cutout
+
+import (
+	"context"
+	"net/http"
+)
+
+// CallInfo describes a call made through a circuit breaker, for its Instrumentation
+//
+// Name & Method are the url & the method of the request. Attempts is the number of times the request was attempted,
+// retries included. FallbackLevel is the fallback function that served the call(1 for the first one), zero if none
+// did. State is the state of the circuit once the call is done. StatusCode is the status code of the response, if any,
+// and Err the error returned to the caller.
+type CallInfo struct {
+	Breaker       string
+	Name          string
+	Method        string
+	State         string
+	Attempts      int
+	FallbackLevel int
+	StatusCode    int
+	Err           error
+}
+
+// Instrumentation observes the calls made with Call, CallWithCustomRequest & their context variants, e.g, to trace
+// them(see the cutoutotel package for OpenTelemetry)
+//
+// StartCall is called before the call is made, the context it returns is the one the call is made within. Inject
+// adds the headers carrying that context, like the trace context, to the outgoing request. EndCall is called with the
+// context returned from StartCall once the call is done, the info is filled in by then.
+type Instrumentation interface {
+	StartCall(ctx context.Context, info *CallInfo) context.Context
+	Inject(ctx context.Context, header http.Header)
+	EndCall(ctx context.Context, info *CallInfo)
+}
+
+type callInfoKey struct{}
+
+// the info of the instrumented call the context belongs to, nil if it is not instrumented
+func callInfoFrom(ctx context.Context) *CallInfo {
+	info, _ := ctx.Value(callInfoKey{}).(*CallInfo)
+	return info
+}
+
+// start instrumenting a call, returns the context to make the call within and the function ending the call
+func (c *CircuitBreaker) startCall(ctx context.Context, name, method string) (context.Context, func(*Response, error)) {
+	if c.Instrumentation == nil {
+		return ctx, func(*Response, error) {}
+	}
+
+	info := &CallInfo{Breaker: c.Name, Name: name, Method: method, Attempts: 1}
+	ctx = c.Instrumentation.StartCall(context.WithValue(ctx, callInfoKey{}, info), info)
+
+	return ctx, func(resp *Response, err error) {
+		info.State = c.State()
+		info.Err = err
+		if resp != nil && resp.Response != nil {
+			info.StatusCode = resp.StatusCode
+		}
+		c.Instrumentation.EndCall(ctx, info)
+	}
+}
+
+// a context carrying the info of the instrumented call of another context, if any
+func withCallInfo(ctx, from context.Context) context.Context {
+	if info := callInfoFrom(from); info != nil {
+		return context.WithValue(ctx, callInfoKey{}, info)
+	}
+	return ctx
+}
+
+// the headers carrying the context of an instrumented call, nil if the call is not instrumented
+func (c *CircuitBreaker) injectHeaders(ctx context.Context) http.Header {
+	if c.Instrumentation == nil {
+		return nil
+	}
+
+	header := http.Header{}
+	c.Instrumentation.Inject(ctx, header)
+	return header
+}
+
+// a copy of the request with the headers carrying the context of the call
+func (r *Request) withHeaders(header http.Header) *Request {
+	if len(header) == 0 {
+		return r
+	}
+
+	req := *r
+	req.Headers = make(map[string]string, len(r.Headers)+len(header))
+	for key, value := range r.Headers {
+		req.Headers[key] = value
+	}
+	for key := range header {
+		req.Headers[key] = header.Get(key)
+	}
+	return &req
+}
+
+// a copy of the custom request with the headers carrying the context of the call
+func withHeaders(req *http.Request, header http.Header) *http.Request {
+	if len(header) == 0 {
+		return req
+	}
+
+	req = req.Clone(req.Context())
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	return req
+}
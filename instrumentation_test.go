@@ -0,0 +1,109 @@
+package cutout
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type traceKey struct{}
+
+// recordingInstrumentation injects the value of its context key as a header and records the calls it sees
+type recordingInstrumentation struct {
+	mu    sync.Mutex
+	calls []CallInfo
+}
+
+func (ri *recordingInstrumentation) StartCall(ctx context.Context, info *CallInfo) context.Context {
+	return context.WithValue(ctx, traceKey{}, "trace-"+info.Method)
+}
+
+func (ri *recordingInstrumentation) Inject(ctx context.Context, header http.Header) {
+	if v, ok := ctx.Value(traceKey{}).(string); ok {
+		header.Set("X-Trace", v)
+	}
+}
+
+func (ri *recordingInstrumentation) EndCall(ctx context.Context, info *CallInfo) {
+	if ctx.Value(traceKey{}) == nil {
+		panic("EndCall should get the context returned from StartCall")
+	}
+	ri.mu.Lock()
+	ri.calls = append(ri.calls, *info)
+	ri.mu.Unlock()
+}
+
+func TestInstrumentation(t *testing.T) {
+	var traces []string
+	var mu sync.Mutex
+	failures := 1
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		traces = append(traces, r.Header.Get("X-Trace"))
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	ri := &recordingInstrumentation{}
+	cb := NewCircuitBreaker(2, time.Minute)
+	cb.Name = "svc"
+	cb.Instrumentation = ri
+
+	req := &Request{
+		URL:     ts.URL,
+		Method:  http.MethodGet,
+		Headers: map[string]string{"Accept": "text/plain"},
+		TimeOut: time.Second,
+		Retry:   &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+	}
+	if _, err := cb.Call(req); err != nil {
+		t.Fatal(err)
+	}
+	if len(req.Headers) != 1 {
+		t.Errorf("The request should not be modified, got the headers %v", req.Headers)
+	}
+
+	custom, _ := http.NewRequest(http.MethodPost, ts.URL, nil)
+	if _, err := cb.CallWithCustomRequest(custom, nil); err != nil {
+		t.Fatal(err)
+	}
+	if custom.Header.Get("X-Trace") != "" {
+		t.Error("The custom request should not be modified")
+	}
+
+	tripCircuit(t, cb)
+	cb.Call(req, func() (*Response, error) {
+		return nil, errors.New("no cache")
+	}, cacheFallback)
+
+	mu.Lock()
+	if len(traces) < 3 || traces[0] != "trace-GET" || traces[1] != "trace-GET" || traces[2] != "trace-POST" {
+		t.Errorf("Expected the trace headers to be injected, got %v", traces)
+	}
+	mu.Unlock()
+
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+	calls := ri.calls
+	if len(calls) < 3 {
+		t.Fatalf("Expected the calls to be recorded, got %d", len(calls))
+	}
+	if c := calls[0]; c.Breaker != "svc" || c.Method != http.MethodGet || c.Attempts != 2 ||
+		c.StatusCode != http.StatusOK || c.State != ClosedState || c.Err != nil || c.FallbackLevel != 0 {
+		t.Errorf("Invalid call info of the retried call: %+v", c)
+	}
+	if c := calls[1]; c.Method != http.MethodPost || c.Attempts != 1 || c.StatusCode != http.StatusOK {
+		t.Errorf("Invalid call info of the custom call: %+v", c)
+	}
+	if c := calls[len(calls)-1]; c.FallbackLevel != 2 || c.State != OpenState || c.Err != nil {
+		t.Errorf("Invalid call info of the fallback call: %+v", c)
+	}
+}
@@ -0,0 +1,248 @@
+// Package metrics exports the metrics of the cutout circuit breakers in the Prometheus text exposition format, without
+// depending on the Prometheus client library.
+//
+// Every metric is labeled by the name of the circuit breaker, the counters & the latency histogram are exported for
+// the circuit breakers with analytics initialized only.
+//
+// Example:
+//
+//  registry := cutout.NewRegistry(func(name string) *cutout.CircuitBreaker {
+// 	 cb := cutout.NewCircuitBreaker(10, 15*time.Second)
+// 	 cb.InitAnalytics()
+// 	 return cb
+//  })
+//
+//  http.Handle("/metrics", metrics.Handler(registry))
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Anondo/cutout"
+)
+
+// ContentType is the content type of the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultNamespace prefixes the names of the metrics, unless told otherwise
+const DefaultNamespace = "cutout"
+
+// DefaultBuckets are the upper bounds of the buckets of the latency histogram, unless told otherwise
+var DefaultBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// the states a circuit breaker can be in
+var states = []string{cutout.ClosedState, cutout.OpenState, cutout.HalfOpenState}
+
+// Exporter exports the metrics of the circuit breakers in the Registry(cutout.DefaultRegistry if none is provided)
+//
+// The names of the metrics are prefixed by the Namespace(DefaultNamespace if empty), the latency histogram has the
+// Buckets(DefaultBuckets if none are provided). The latencies are exported from the histograms of the analytics, whose
+// own buckets are about 6% wide, so a latency just under one of the Buckets may be counted in the next one up.
+type Exporter struct {
+	Registry  *cutout.Registry
+	Namespace string
+	Buckets   []time.Duration
+}
+
+// NewExporter creates a new exporter of the circuit breakers in the given registry
+func NewExporter(registry *cutout.Registry) *Exporter {
+	return &Exporter{
+		Registry: registry,
+	}
+}
+
+// Handler returns an http handler serving the metrics of the circuit breakers in the given registry
+func Handler(registry *cutout.Registry) http.Handler {
+	return NewExporter(registry)
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	e.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format
+func (e *Exporter) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	e.write(cw, e.registry().Snapshot())
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+func (e *Exporter) registry() *cutout.Registry {
+	if e.Registry != nil {
+		return e.Registry
+	}
+	return cutout.DefaultRegistry
+}
+
+func (e *Exporter) namespace() string {
+	if e.Namespace != "" {
+		return e.Namespace
+	}
+	return DefaultNamespace
+}
+
+func (e *Exporter) buckets() []time.Duration {
+	if len(e.Buckets) != 0 {
+		return e.Buckets
+	}
+	return DefaultBuckets
+}
+
+// a counter of the analytics
+type counter struct {
+	name   string
+	help   string
+	labels string
+	value  func(*cutout.Analytics) int
+}
+
+var counters = []counter{
+	{"calls_total", "The calls made through the circuit breaker.", "",
+		func(a *cutout.Analytics) int { return a.TotalCalls }},
+	{"requests_total", "The requests sent to the service.", "",
+		func(a *cutout.Analytics) int { return a.RequestSent }},
+	{"failures_total", "The requests that failed.", "",
+		func(a *cutout.Analytics) int { return a.TotalFailures }},
+	{"fallbacks_total", "The calls served by the fallbacks.", "",
+		func(a *cutout.Analytics) int { return a.FallbackCalls }},
+	{"rejections_total", "The calls rejected by the circuit breaker.", `reason="circuit_open"`,
+		func(a *cutout.Analytics) int { return a.CircuitRejections }},
+	{"rejections_total", "", `reason="bulkhead"`,
+		func(a *cutout.Analytics) int { return a.BulkheadRejections }},
+	{"rejections_total", "", `reason="rate_limit"`,
+		func(a *cutout.Analytics) int { return a.RateLimitRejections }},
+	{"rejections_total", "", `reason="concurrency_limit"`,
+		func(a *cutout.Analytics) int { return a.ConcurrencyLimitRejections }},
+	{"slow_calls_total", "The requests slower than the slow call threshold.", "",
+		func(a *cutout.Analytics) int { return a.SlowCalls }},
+	{"cancelled_calls_total", "The requests cancelled by the caller.", "",
+		func(a *cutout.Analytics) int { return a.CancelledCalls }},
+	{"retries_total", "The retries of the requests.", "",
+		func(a *cutout.Analytics) int { return a.Retries }},
+	{"hedges_total", "The hedged copies of the requests sent.", "",
+		func(a *cutout.Analytics) int { return a.Hedges }},
+}
+
+func (e *Exporter) write(w *countingWriter, snapshots []cutout.Snapshot) {
+	ns := e.namespace()
+
+	w.header(ns+"_state", "The state of the circuit breaker, 1 for the current state.", "gauge")
+	for _, s := range snapshots {
+		for _, state := range states {
+			value := 0
+			if s.State == state || (s.State == "" && state == cutout.ClosedState) { // closed until called
+				value = 1
+			}
+			w.sample(ns+"_state", labels(s.Name, `state="`+state+`"`), strconv.Itoa(value))
+		}
+	}
+
+	w.header(ns+"_fail_count", "The consecutive failures of the circuit breaker.", "gauge")
+	for _, s := range snapshots {
+		w.sample(ns+"_fail_count", labels(s.Name, ""), strconv.Itoa(s.FailCount))
+	}
+
+	for _, c := range counters {
+		if c.help != "" {
+			w.header(ns+"_"+c.name, c.help, "counter")
+		}
+		for _, s := range snapshots {
+			if s.Analytics != nil {
+				w.sample(ns+"_"+c.name, labels(s.Name, c.labels), strconv.Itoa(c.value(s.Analytics)))
+			}
+		}
+	}
+
+	name := ns + "_request_duration_seconds"
+	w.header(name, "The latency of the requests sent to the service.", "histogram")
+	for _, s := range snapshots {
+		if s.Analytics != nil && s.Analytics.Latency != nil {
+			e.writeHistogram(w, name, s.Name, s.Analytics.Latency)
+		}
+	}
+}
+
+// write the latency histogram of a circuit breaker, each latency is counted in the first bucket at or above the upper
+// bound of the histogram bucket it was recorded into, not the latency itself, so the buckets are slightly undercounted
+func (e *Exporter) writeHistogram(w *countingWriter, name, breaker string, h *cutout.Histogram) {
+	latencies := h.Buckets()
+
+	var count uint64
+	for _, le := range e.buckets() {
+		for len(latencies) > 0 && latencies[0].UpperBound <= le {
+			count += latencies[0].Count
+			latencies = latencies[1:]
+		}
+		w.sample(name+"_bucket", labels(breaker, `le="`+seconds(le)+`"`), strconv.FormatUint(count, 10))
+	}
+	w.sample(name+"_bucket", labels(breaker, `le="+Inf"`), strconv.FormatUint(h.Count(), 10))
+	w.sample(name+"_sum", labels(breaker, ""), seconds(h.Sum()))
+	w.sample(name+"_count", labels(breaker, ""), strconv.FormatUint(h.Count(), 10))
+}
+
+// the labels of a sample of the given circuit breaker
+func labels(breaker, extra string) string {
+	l := `breaker="` + escape(breaker) + `"`
+	if extra != "" {
+		l += "," + extra
+	}
+	return l
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escape a label value
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'g', -1, 64)
+}
+
+// countingWriter writes the metrics line by line, keeping the count of the bytes written & the first error
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) printf(format string, args ...interface{}) {
+	if cw.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(cw.w, format, args...)
+	cw.n += int64(n)
+	cw.err = err
+}
+
+func (cw *countingWriter) header(name, help, typ string) {
+	cw.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (cw *countingWriter) sample(name, labels, value string) {
+	cw.printf("%s{%s} %s\n", name, labels, value)
+}
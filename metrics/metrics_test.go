@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Anondo/cutout"
+)
+
+func TestHandler(t *testing.T) {
+	svc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer svc.Close()
+
+	registry := cutout.NewRegistry(func(name string) *cutout.CircuitBreaker {
+		cb := cutout.NewCircuitBreaker(1, time.Minute)
+		if name != "idle" {
+			cb.InitAnalytics()
+		}
+		return cb
+	})
+
+	payments := registry.Get(`pay"ments`)
+	payments.Call(&cutout.Request{URL: svc.URL, Method: http.MethodGet, TimeOut: time.Second})
+	payments.Call(&cutout.Request{URL: svc.URL + "/fail", Method: http.MethodGet, TimeOut: time.Second})
+	payments.Call(&cutout.Request{URL: svc.URL, Method: http.MethodGet, TimeOut: time.Second},
+		func() (*cutout.Response, error) {
+			return &cutout.Response{BodyString: "cache"}, nil
+		})
+	registry.Get("idle")
+
+	ts := httptest.NewServer(Handler(registry))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != ContentType {
+		t.Errorf("Expected the content type %q, got %q", ContentType, ct)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scraped := string(body)
+
+	for _, line := range []string{
+		"# TYPE cutout_state gauge",
+		`cutout_state{breaker="idle",state="CLOSED"} 1`,
+		`cutout_state{breaker="pay\"ments",state="OPEN"} 1`,
+		`cutout_state{breaker="pay\"ments",state="CLOSED"} 0`,
+		`cutout_fail_count{breaker="pay\"ments"} 1`,
+		"# TYPE cutout_calls_total counter",
+		`cutout_calls_total{breaker="pay\"ments"} 3`,
+		`cutout_requests_total{breaker="pay\"ments"} 2`,
+		`cutout_failures_total{breaker="pay\"ments"} 1`,
+		`cutout_fallbacks_total{breaker="pay\"ments"} 1`,
+		`cutout_rejections_total{breaker="pay\"ments",reason="circuit_open"} 1`,
+		`cutout_rejections_total{breaker="pay\"ments",reason="bulkhead"} 0`,
+		"# TYPE cutout_request_duration_seconds histogram",
+		`cutout_request_duration_seconds_bucket{breaker="pay\"ments",le="+Inf"} 2`,
+		`cutout_request_duration_seconds_count{breaker="pay\"ments"} 2`,
+	} {
+		if !strings.Contains(scraped, line+"\n") {
+			t.Errorf("Expected the line %q in:\n%s", line, scraped)
+		}
+	}
+
+	if strings.Contains(scraped, `cutout_calls_total{breaker="idle"}`) {
+		t.Error("The counters of a circuit breaker without analytics should not be exported")
+	}
+	if n := strings.Count(scraped, "# TYPE cutout_rejections_total"); n != 1 {
+		t.Errorf("Expected the rejections to be declared once, got %d", n)
+	}
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	h := &cutout.Histogram{}
+	h.Record(3 * time.Millisecond)
+	h.Record(40 * time.Millisecond)
+	h.Record(40 * time.Millisecond)
+	h.Record(time.Minute)
+
+	e := &Exporter{Namespace: "test", Buckets: []time.Duration{10 * time.Millisecond, 100 * time.Millisecond}}
+	var sb strings.Builder
+	cw := &countingWriter{w: bufio.NewWriter(&sb)}
+	e.writeHistogram(cw, "test_latency", "b", h)
+	cw.w.Flush()
+
+	want := `test_latency_bucket{breaker="b",le="0.01"} 1
+test_latency_bucket{breaker="b",le="0.1"} 3
+test_latency_bucket{breaker="b",le="+Inf"} 4
+test_latency_sum{breaker="b"} 60.083
+test_latency_count{breaker="b"} 4
+`
+	if sb.String() != want {
+		t.Errorf("Invalid histogram, wanted:\n%s\ngot:\n%s", want, sb.String())
+	}
+	if cw.n != int64(len(want)) {
+		t.Errorf("Expected %d bytes written, got %d", len(want), cw.n)
+	}
+}
@@ -0,0 +1,118 @@
+package cutout
+
+import "time"
+
+// Outcome holds the information of a call let through by the circuit, which a TripPolicy decides on
+type Outcome struct {
+	Err                 error
+	Duration            time.Duration
+	OccurredAt          time.Time
+	ConsecutiveFailures int
+	Slow                bool
+}
+
+// Failed reports whether the call has failed
+func (o Outcome) Failed() bool {
+	return o.Err != nil
+}
+
+func (o Outcome) windowOutcome() windowOutcome {
+	return windowOutcome{failed: o.Failed(), slow: o.Slow}
+}
+
+// TripPolicy decides when the circuit opens
+//
+// The circuit breaker consults its policy after each call made in the closed state and resets it once the circuit
+// closes again. A policy is only ever called with the circuit breaker lock held, so it needn't be safe for concurrent
+// use, but a stateful policy must not be shared among circuit breakers.
+type TripPolicy interface {
+	// Trip records the outcome of a call and reports whether the circuit should open
+	Trip(o Outcome) bool
+	// Reset clears the outcomes recorded so far
+	Reset()
+}
+
+// TripFunc is an adapter to use an ordinary function as a stateless TripPolicy
+//
+// Example:
+//
+//  cb := cutout.NewCircuitBreakerWithPolicy(cutout.TripFunc(func(o cutout.Outcome) bool {
+// 	 return errors.Is(o.Err, errQuotaExceeded)
+//  }), time.Minute)
+type TripFunc func(o Outcome) bool
+
+// Trip calls f(o)
+func (f TripFunc) Trip(o Outcome) bool {
+	return f(o)
+}
+
+// Reset does nothing as a TripFunc holds no outcomes
+func (f TripFunc) Reset() {}
+
+// ConsecutiveFailures returns a TripPolicy opening the circuit after the given number of consecutive failures, which is
+// the default policy of a circuit breaker with its FailThreshold as the threshold
+func ConsecutiveFailures(threshold int) TripPolicy {
+	return TripFunc(func(o Outcome) bool {
+		return o.Failed() && o.ConsecutiveFailures >= threshold
+	})
+}
+
+// FailureRate returns a TripPolicy opening the circuit when the failure rate over the sliding window reaches
+// window.FailureRate
+func FailureRate(window SlidingWindow) TripPolicy {
+	return &ratePolicy{config: window, trip: func(wc windowCounts) bool {
+		return wc.reached(wc.failures, window.MinCalls, window.FailureRate)
+	}}
+}
+
+// SlowCallRate returns a TripPolicy opening the circuit when the rate of the slow calls(see
+// CircuitBreaker.SlowCallThreshold) over the sliding window reaches window.SlowCallRate
+func SlowCallRate(window SlidingWindow) TripPolicy {
+	return &ratePolicy{config: window, trip: func(wc windowCounts) bool {
+		return wc.reached(wc.slowCalls, window.MinCalls, window.SlowCallRate)
+	}}
+}
+
+// ratePolicy trips based on the outcome counts over a sliding window
+type ratePolicy struct {
+	config SlidingWindow
+	window *slidingWindow
+	trip   func(wc windowCounts) bool
+}
+
+func (p *ratePolicy) Trip(o Outcome) bool {
+	if p.window == nil {
+		p.window = newSlidingWindow(p.config.Size, p.config.Duration)
+	}
+	p.window.record(o.windowOutcome())
+
+	return p.trip(p.window.counts())
+}
+
+func (p *ratePolicy) Reset() {
+	p.window = nil
+}
+
+// TripOnAny returns a TripPolicy opening the circuit as soon as any of the given policies would, every policy records
+// every outcome
+func TripOnAny(policies ...TripPolicy) TripPolicy {
+	return anyPolicy(policies)
+}
+
+type anyPolicy []TripPolicy
+
+func (ap anyPolicy) Trip(o Outcome) bool {
+	trip := false
+	for _, p := range ap {
+		if p.Trip(o) {
+			trip = true
+		}
+	}
+	return trip
+}
+
+func (ap anyPolicy) Reset() {
+	for _, p := range ap {
+		p.Reset()
+	}
+}
@@ -0,0 +1,116 @@
+package cutout
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestConsecutiveFailures(t *testing.T) {
+	p := ConsecutiveFailures(3)
+	errFailed := errors.New("failed")
+
+	if p.Trip(Outcome{Err: errFailed, ConsecutiveFailures: 2}) {
+		t.Error("The policy shouldn't trip below the threshold")
+	}
+	if !p.Trip(Outcome{Err: errFailed, ConsecutiveFailures: 3}) {
+		t.Error("The policy should trip on the threshold")
+	}
+	if p.Trip(Outcome{ConsecutiveFailures: 0}) {
+		t.Error("The policy shouldn't trip on a success")
+	}
+}
+
+func TestTripOnAny(t *testing.T) {
+	never := TripFunc(func(o Outcome) bool { return false })
+	p := TripOnAny(never, FailureRate(SlidingWindow{Size: 2, MinCalls: 2, FailureRate: 100}))
+	errFailed := errors.New("failed")
+
+	if p.Trip(Outcome{Err: errFailed}) {
+		t.Error("The policy shouldn't trip before the minimum calls")
+	}
+	if !p.Trip(Outcome{Err: errFailed}) {
+		t.Error("The policy should trip as one of its policies did")
+	}
+
+	p.Reset()
+	if p.Trip(Outcome{Err: errFailed}) {
+		t.Error("The policy shouldn't trip after a reset")
+	}
+}
+
+func TestCircuitBreakerWithCustomPolicy(t *testing.T) {
+	cb := NewCircuitBreakerWithPolicy(TripFunc(func(o Outcome) bool { // only slow failures count
+		return o.Failed() && o.Duration > 50*time.Millisecond
+	}), time.Minute)
+
+	ts := newTestService(http.StatusInternalServerError, false)
+	defer ts.Close()
+
+	for i := 0; i < 5; i++ {
+		cb.Call(ts.request())
+	}
+	if state := cb.State(); state != ClosedState {
+		t.Fatalf("Incorrect state of the circuit received, wanted %s got %s", ClosedState, state)
+	}
+
+	slow := newTestService(http.StatusInternalServerError, true)
+	defer slow.Close()
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		slow.release()
+	}()
+	cb.Call(slow.request())
+
+	if _, err := cb.Call(ts.request()); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected %v, got %v", ErrCircuitOpen, err)
+	}
+}
+
+func TestSlowCallRate(t *testing.T) {
+	cb := NewCircuitBreakerWithPolicy(SlowCallRate(SlidingWindow{
+		Size:         4,
+		MinCalls:     2,
+		SlowCallRate: 50,
+	}), time.Minute)
+	cb.SlowCallThreshold = 30 * time.Millisecond
+	cb.InitAnalytics()
+	event := make(chan string, 10)
+	cb.InitEvent(event)
+
+	fast := newTestService(http.StatusOK, false)
+	defer fast.Close()
+	slow := newTestService(http.StatusOK, false)
+	defer slow.Close()
+	slow.setLatency(60 * time.Millisecond)
+
+	cb.Call(fast.request())
+	cb.Call(fast.request())
+	if _, err := cb.Call(slow.request()); err != nil {
+		t.Fatalf("A slow call is not a failure, got %v", err)
+	}
+	if state := cb.State(); state != ClosedState {
+		t.Fatalf("Incorrect state of the circuit received, wanted %s got %s", ClosedState, state)
+	}
+
+	cb.Call(slow.request()) // 2 of the 4 calls are slow
+
+	if _, err := cb.Call(fast.request()); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected %v, got %v", ErrCircuitOpen, err)
+	}
+	if anlcts := cb.GetAnalytics(); anlcts.SlowCalls != 2 || anlcts.TotalFailures != 0 {
+		t.Errorf("Invalid analytics, wanted 2 slow calls & no failures, got %d slow calls & %d failures",
+			anlcts.SlowCalls, anlcts.TotalFailures)
+	}
+
+	slowEvents := 0
+	for len(event) > 0 {
+		if <-event == SlowCallEvent {
+			slowEvents++
+		}
+	}
+	if slowEvents != 2 {
+		t.Errorf("Invalid number of slow call events, wanted:%d, got:%d", 2, slowEvents)
+	}
+}
@@ -0,0 +1,121 @@
+package cutout
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket limiting the rate of the calls made through the circuit breakers it is attached to,
+// handy for the services with hard quotas
+//
+// The bucket holds up to Burst tokens(one if zero) and is refilled with Rate tokens per second. Every call takes a
+// token, a call finding the bucket empty is rejected with ErrRateLimited and served by the fallbacks, unless Wait is
+// true, in which case it waits for a token as long as its context allows, for MaxWait at most if provided. A call
+// rejected by the rate limiter is not counted as a failure of the service, a call rejected by the circuit doesn't use
+// up a token.
+//
+// A RateLimiter is safe for concurrent use, attaching the same one to many circuit breakers limits their calls
+// altogether.
+//
+// Example:
+//
+//  cb := cutout.NewCircuitBreaker(5, 15*time.Second)
+//  cb.RateLimiter = cutout.NewRateLimiter(10, 20) // 10 calls per second, in bursts of up to 20 calls
+type RateLimiter struct {
+	Rate    float64
+	Burst   int
+	Wait    bool
+	MaxWait time.Duration
+	mu      sync.Mutex
+	tokens  float64
+	last    time.Time
+}
+
+// NewRateLimiter creates a new rate limiter letting through rate calls per second, in bursts of up to burst calls
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		Rate:  rate,
+		Burst: burst,
+	}
+}
+
+func (l *RateLimiter) burst() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return 1
+}
+
+// take a token, returns how long the caller has to wait for it or false if the wait would be longer than maxWait
+func (l *RateLimiter) reserve(now time.Time, maxWait time.Duration) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.last.IsZero() { // starts with a full bucket
+		l.tokens = l.burst()
+	} else if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens += elapsed.Seconds() * l.Rate
+		if l.tokens > l.burst() {
+			l.tokens = l.burst()
+		}
+	}
+	if now.After(l.last) {
+		l.last = now
+	}
+
+	var wait time.Duration
+	if l.tokens < 1 {
+		if l.Rate <= 0 { // never refilled
+			return 0, false
+		}
+		wait = time.Duration((1 - l.tokens) / l.Rate * float64(time.Second))
+	}
+	if wait > maxWait {
+		return 0, false
+	}
+
+	l.tokens--
+	return wait, true
+}
+
+// give back a token which was taken but not used
+func (l *RateLimiter) cancel() {
+	l.mu.Lock()
+	l.tokens = math.Min(l.tokens+1, l.burst())
+	l.mu.Unlock()
+}
+
+// take a token for a call, waiting for it if allowed, returns ErrRateLimited if there is none in time
+func (l *RateLimiter) take(ctx context.Context, wait bool) error {
+	var maxWait time.Duration
+	if wait && l.Wait {
+		maxWait = l.MaxWait
+		if maxWait == 0 {
+			maxWait = time.Duration(1<<63 - 1)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < maxWait { // no point waiting past the deadline
+			maxWait = time.Until(deadline)
+		}
+	}
+
+	delay, ok := l.reserve(time.Now(), maxWait)
+	if !ok {
+		return ErrRateLimited
+	}
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.cancel()
+		return ctx.Err()
+	}
+}
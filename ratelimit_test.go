@@ -0,0 +1,144 @@
+package cutout
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	l := NewRateLimiter(10, 2)
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if wait, ok := l.reserve(now, 0); !ok || wait != 0 {
+			t.Fatalf("The burst should be let through right away, got %v, %v", wait, ok)
+		}
+	}
+	if _, ok := l.reserve(now, 0); ok {
+		t.Error("The empty bucket should not let the call through")
+	}
+	if wait, ok := l.reserve(now, time.Second); !ok || wait != 100*time.Millisecond {
+		t.Errorf("Expected to wait %v for the next token, got %v, %v", 100*time.Millisecond, wait, ok)
+	}
+	if wait, ok := l.reserve(now.Add(time.Second), 0); !ok || wait != 0 {
+		t.Errorf("The bucket should have been refilled, got %v, %v", wait, ok)
+	}
+}
+
+func TestRateLimiterRejectsToFallbacks(t *testing.T) {
+	ts := newTestService(http.StatusOK, false)
+	defer ts.Close()
+
+	cb := NewCircuitBreaker(1, time.Second)
+	cb.RateLimiter = NewRateLimiter(1, 2)
+	cb.InitAnalytics()
+
+	for i := 0; i < 2; i++ {
+		if _, err := cb.Call(ts.request()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := cb.Call(ts.request()); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected %v, got %v", ErrRateLimited, err)
+	}
+	resp, err := cb.Call(ts.request(), cacheFallback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.BodyString != "cache" {
+		t.Errorf("Expected the fallback response, got %q", resp.BodyString)
+	}
+
+	if hits := atomic.LoadInt32(&ts.hits); hits != 2 {
+		t.Errorf("Expected %d hits, got %d", 2, hits)
+	}
+	if cb.State() != ClosedState || cb.FailCount() != 0 {
+		t.Errorf("Rejections should not count as failures, state: %s, fail count: %d", cb.State(), cb.FailCount())
+	}
+	a := cb.GetAnalytics()
+	if a.RateLimitRejections != 2 {
+		t.Errorf("Expected %d rejections, got %d", 2, a.RateLimitRejections)
+	}
+	if a.TotalFailures != 0 {
+		t.Errorf("Expected %d failures, got %d", 0, a.TotalFailures)
+	}
+}
+
+func TestRateLimiterSkippedWhileOpen(t *testing.T) {
+	cb := NewCircuitBreaker(1, time.Minute)
+	tripCircuit(t, cb)
+	cb.RateLimiter = &RateLimiter{Rate: 0.1, Burst: 1, Wait: true}
+
+	ts := newTestService(http.StatusOK, false)
+	defer ts.Close()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := cb.Call(ts.request()); !errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("Expected %v, got %v", ErrCircuitOpen, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("The calls should not have waited for a token, took %v", elapsed)
+	}
+	if _, ok := cb.RateLimiter.reserve(time.Now(), 0); !ok {
+		t.Error("The rejected calls should not have taken the token")
+	}
+}
+
+func TestRateLimiterTokenGivenBack(t *testing.T) {
+	ts := newTestService(http.StatusOK, true)
+	defer ts.Close()
+
+	cb := NewCircuitBreaker(1, time.Second)
+	cb.MaxConcurrentCalls = 1
+	cb.RateLimiter = NewRateLimiter(0.1, 2)
+
+	var wg sync.WaitGroup
+	fillBulkhead(t, cb, ts, &wg)
+
+	if _, err := cb.Call(ts.request()); !errors.Is(err, ErrBulkheadFull) {
+		t.Errorf("Expected %v, got %v", ErrBulkheadFull, err)
+	}
+	ts.release()
+	wg.Wait()
+
+	if _, err := cb.Call(ts.request()); err != nil {
+		t.Errorf("The token of the rejected call should have been given back, got %v", err)
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	ts := newTestService(http.StatusOK, false)
+	defer ts.Close()
+
+	cb := NewCircuitBreaker(1, time.Second)
+	cb.RateLimiter = &RateLimiter{Rate: 20, Burst: 1, Wait: true}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := cb.Call(ts.request()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("The calls should have waited for the tokens, took %v", elapsed)
+	}
+
+	// the deadline comes before the next token
+	cb.RateLimiter = &RateLimiter{Rate: 1, Burst: 1, Wait: true}
+	if _, err := cb.Call(ts.request()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := cb.CallContext(ctx, ts.request()); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected %v, got %v", ErrRateLimited, err)
+	}
+}
@@ -0,0 +1,119 @@
+package cutout
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Registry holds circuit breakers by their names, so that they can be fetched from anywhere, listed and inspected
+// together. A Registry is safe for concurrent use by multiple goroutines.
+//
+// The circuit breakers are created with NewBreaker, like the ones of the DefaultRegistry if it is not provided.
+// NewBreaker may be called more than once for the same name by concurrent calls to Get, only one of the circuit
+// breakers created is kept.
+//
+// Example:
+//
+//  registry := cutout.NewRegistry(func(name string) *cutout.CircuitBreaker {
+// 	 cb := cutout.NewCircuitBreaker(10, 15*time.Second)
+// 	 cb.InitAnalytics()
+// 	 return cb
+//  })
+//
+//  resp, err := registry.Get("payments").Call(&req, theFallbackFunc)
+type Registry struct {
+	NewBreaker func(name string) *CircuitBreaker
+	mu         sync.Mutex
+	breakers   map[string]*CircuitBreaker
+}
+
+// DefaultRegistry is the registry shared by the whole program, its circuit breakers open after 5 consecutive
+// failures with a health check period of 30 seconds
+var DefaultRegistry = NewRegistry(newDefaultBreaker)
+
+// creates the circuit breakers of the registries with no NewBreaker of their own
+func newDefaultBreaker(name string) *CircuitBreaker {
+	return NewCircuitBreaker(5, 30*time.Second)
+}
+
+// NewRegistry creates a new registry, the circuit breakers are created with newBreaker
+func NewRegistry(newBreaker func(name string) *CircuitBreaker) *Registry {
+	return &Registry{
+		NewBreaker: newBreaker,
+	}
+}
+
+// Get returns the circuit breaker of the given name, creating it if there is none yet
+func (r *Registry) Get(name string) *CircuitBreaker {
+	r.mu.Lock()
+	cb, ok := r.breakers[name]
+	r.mu.Unlock()
+	if ok {
+		return cb
+	}
+
+	newBreaker := r.NewBreaker
+	if newBreaker == nil {
+		newBreaker = newDefaultBreaker
+	}
+	cb = newBreaker(name) // outside the lock, so that it can use the registry itself
+	cb.Name = name
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.breakers[name]; ok { // created by another call in the meantime
+		return existing
+	}
+	if r.breakers == nil {
+		r.breakers = make(map[string]*CircuitBreaker)
+	}
+	r.breakers[name] = cb
+
+	return cb
+}
+
+// Names returns the names of the circuit breakers in the registry in sorted order
+func (r *Registry) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.names()
+}
+
+// List returns the circuit breakers in the registry sorted by their names
+func (r *Registry) List() []*CircuitBreaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := r.names()
+	breakers := make([]*CircuitBreaker, len(names))
+	for i, name := range names {
+		breakers[i] = r.breakers[name]
+	}
+
+	return breakers
+}
+
+func (r *Registry) names() []string {
+	names := make([]string, 0, len(r.breakers))
+	for name := range r.breakers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Snapshot returns the snapshots of the circuit breakers in the registry sorted by their names
+func (r *Registry) Snapshot() []Snapshot {
+	breakers := r.List()
+
+	snapshots := make([]Snapshot, len(breakers))
+	for i, cb := range breakers {
+		snapshots[i] = cb.Snapshot()
+	}
+
+	return snapshots
+}
@@ -0,0 +1,112 @@
+package cutout
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry(func(name string) *CircuitBreaker {
+		cb := NewCircuitBreaker(1, time.Minute)
+		cb.InitAnalytics()
+		return cb
+	})
+
+	var wg sync.WaitGroup
+	breakers := make([]*CircuitBreaker, 50)
+	for i := range breakers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			breakers[i] = registry.Get("payments")
+		}(i)
+	}
+	wg.Wait()
+	for _, cb := range breakers {
+		if cb != breakers[0] {
+			t.Fatal("The same circuit breaker should have been returned for the same name")
+		}
+	}
+
+	ts := newTestService(http.StatusInternalServerError, false)
+	defer ts.Close()
+
+	events := make(chan Event, 10)
+	registry.Get("payments").InitEventStream(events)
+	registry.Get("payments").Call(ts.request())
+	registry.Get("inventory").Call(ts.request())
+	registry.Get("accounts")
+
+	names := registry.Names()
+	want := []string{"accounts", "inventory", "payments"}
+	if len(names) != len(want) {
+		t.Fatalf("Invalid names, wanted %v got %v", want, names)
+	}
+	for i := range want {
+		if names[i] != want[i] || registry.List()[i].Name != want[i] {
+			t.Fatalf("Invalid names, wanted %v got %v", want, names)
+		}
+	}
+
+	snapshots := registry.Snapshot()
+	payments := snapshots[2]
+	if payments.Name != "payments" || payments.State != ClosedState || payments.FailCount != 1 {
+		t.Errorf("Invalid snapshot, got %+v", payments)
+	}
+	if payments.Analytics == nil || payments.Analytics.TotalFailures != 1 {
+		t.Fatalf("The snapshot should hold the analytics, got %+v", payments.Analytics)
+	}
+	if rr := payments.Analytics.RequestRecords[0]; rr.Breaker != "payments" {
+		t.Errorf("The request record should hold the name of the circuit breaker, got %q", rr.Breaker)
+	}
+
+	for _, wantType := range []string{StateChangeEvent, FailureEvent} {
+		e := <-events
+		if e.Type != wantType || e.Breaker != "payments" || e.State != ClosedState {
+			t.Errorf("Invalid event, wanted a %s event of payments, got %+v", wantType, e)
+		}
+	}
+}
+
+func TestRegistryDefaultBreaker(t *testing.T) {
+	registry := &Registry{}
+	if cb := registry.Get("payments"); cb.FailThreshold != 5 || cb.HealthCheckPeriod != 30*time.Second {
+		t.Errorf("Expected the circuit breaker of the DefaultRegistry, got %d failures, %v", cb.FailThreshold,
+			cb.HealthCheckPeriod)
+	}
+
+	tr := NewKeyedTransport(nil, nil, nil, nil)
+	if cb := tr.Breaker("example.com"); cb.Name != "example.com" {
+		t.Errorf("Expected the circuit breaker example.com, got %s", cb.Name)
+	}
+}
+
+func TestRegistryNewBreakerUsingRegistry(t *testing.T) {
+	var registry *Registry
+	registry = NewRegistry(func(name string) *CircuitBreaker {
+		cb := NewCircuitBreaker(1, time.Second)
+		if name != "parent" {
+			cb.FailThreshold = registry.Get("parent").FailThreshold + 1
+		}
+		return cb
+	})
+
+	done := make(chan *CircuitBreaker)
+	go func() {
+		done <- registry.Get("child")
+	}()
+
+	select {
+	case cb := <-done:
+		if cb.FailThreshold != 2 {
+			t.Errorf("Expected %d failures, got %d", 2, cb.FailThreshold)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Get deadlocked")
+	}
+	if names := registry.Names(); len(names) != 2 {
+		t.Errorf("Expected %d circuit breakers, got %v", 2, names)
+	}
+}
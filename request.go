@@ -3,12 +3,17 @@ package cutout
 import (
 	"bytes"
 	"context"
-	"errors"
 	"net/http"
 	"time"
 )
 
 // Request represents the data needed to make http requests
+//
+// After a request fails to get a response(e.g, it times out), the next requests made through the same circuit breaker
+// get the timeout returned by BackOff, growing with every such failure up to MaxTimeOut(ten times the TimeOut if
+// zero) and back to TimeOut after a success. The failures are counted per circuit breaker, not per request, so a
+// request timing out backs off the timeout of all the requests made through the same circuit breaker, as they are
+// most likely calling the same service. A Request is never modified by the circuit breaker, so it can be shared.
 type Request struct {
 	URL           string
 	Method        string
@@ -17,6 +22,9 @@ type Request struct {
 	AllowedStatus []int
 	TimeOut       time.Duration
 	BackOff       func(time.Duration) time.Duration
+	MaxTimeOut    time.Duration
+	Retry         *RetryPolicy
+	Hedge         *HedgePolicy
 }
 
 // NewRequest is the factory function for requests i.e, creates a new request
@@ -41,14 +49,50 @@ func (r *Request) isAllowedStatus(status int) bool {
 	return false
 }
 
-func (r *Request) makeRequest() (*Response, error) {
+// whether the status code counts as a failure, i.e, it is not one of the allowed ones or, if none are allowed
+// explicitly, it is an error status
+func isFailureStatus(status int, allowedStatus []int) bool {
+	if len(allowedStatus) != 0 {
+		r := &Request{AllowedStatus: allowedStatus}
+		return !r.isAllowedStatus(status)
+	}
+	return status >= 400
+}
+
+// the timeout of a request after the given number of consecutive failures to get a response
+func (r *Request) backOffTimeOut(failures int) time.Duration {
+	timeout := r.TimeOut
+	if r.BackOff == nil {
+		return timeout
+	}
+
+	maxTimeOut := r.MaxTimeOut
+	if maxTimeOut == 0 {
+		maxTimeOut = 10 * r.TimeOut
+	}
+
+	for i := 0; i < failures && timeout < maxTimeOut; i++ {
+		next := r.BackOff(timeout)
+		if next <= timeout { // not backing off any further
+			break
+		}
+		timeout = next
+	}
+	if timeout > maxTimeOut {
+		timeout = maxTimeOut
+	}
+
+	return timeout
+}
+
+func (r *Request) makeRequest(ctx context.Context, client *http.Client, timeout time.Duration) (*Response, error) {
 
 	req := &http.Request{}
 
 	var err error
 
 	if r.RequestBody != nil {
-		req, err = http.NewRequest(r.Method, r.URL, r.RequestBody)
+		req, err = http.NewRequest(r.Method, r.URL, bytes.NewReader(r.RequestBody.Bytes())) // the body is sent as many times as the request is
 	} else {
 		req, err = http.NewRequest(r.Method, r.URL, nil)
 	}
@@ -63,19 +107,15 @@ func (r *Request) makeRequest() (*Response, error) {
 		}
 	}
 
-	client := http.Client{}
-
-	ctx, cancel := context.WithTimeout(context.Background(), r.TimeOut)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 	req = req.WithContext(ctx)
 
 	resp, err := client.Do(req)
 	if err != nil {
-		if r.BackOff != nil {
-			r.TimeOut = r.BackOff(r.TimeOut)
-		}
-		return nil, err
+		return nil, &noResponseError{err}
 	}
+	defer resp.Body.Close()
 
 	bdy, err := getRespBodyString(resp.Body)
 
@@ -85,26 +125,21 @@ func (r *Request) makeRequest() (*Response, error) {
 
 	finalResponse := &Response{resp, bdy}
 
-	if len(r.AllowedStatus) != 0 {
-		if !r.isAllowedStatus(resp.StatusCode) {
-			return finalResponse, errors.New(bdy)
-		}
-	} else if resp.StatusCode >= 400 {
-		return finalResponse, errors.New(bdy)
+	if isFailureStatus(resp.StatusCode, r.AllowedStatus) {
+		return finalResponse, newHTTPStatusError(finalResponse)
 	}
 
 	return finalResponse, nil
 
 }
 
-func makeCustomRequest(req *http.Request, allowedStatus []int) (*Response, error) {
-
-	client := http.Client{}
+func makeCustomRequest(req *http.Request, allowedStatus []int, client *http.Client) (*Response, error) {
 
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 
 	bdy, err := getRespBodyString(resp.Body)
 
@@ -114,13 +149,8 @@ func makeCustomRequest(req *http.Request, allowedStatus []int) (*Response, error
 
 	finalResponse := &Response{resp, bdy}
 
-	if len(allowedStatus) != 0 {
-		r := &Request{AllowedStatus: allowedStatus}
-		if !r.isAllowedStatus(resp.StatusCode) {
-			return finalResponse, errors.New(bdy)
-		}
-	} else if resp.StatusCode >= 400 {
-		return finalResponse, errors.New(bdy)
+	if isFailureStatus(resp.StatusCode, allowedStatus) {
+		return finalResponse, newHTTPStatusError(finalResponse)
 	}
 
 	return finalResponse, nil
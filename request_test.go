@@ -0,0 +1,126 @@
+package cutout
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCallDoesNotMutateRequest(t *testing.T) {
+	cb := NewCircuitBreaker(100, time.Minute)
+
+	ts := newTestService(http.StatusOK, false)
+	defer ts.Close()
+	ts.setLatency(50 * time.Millisecond)
+
+	req := NewRequest(ts.URL, http.MethodPost, map[string]string{"Content-Type": "application/json"},
+		bytes.NewBuffer([]byte(`{"name":"abcd"}`)), []int{http.StatusOK}, 10*time.Millisecond)
+	req.BackOff = func(t time.Duration) time.Duration {
+		return t * 5
+	}
+	req.Retry = &RetryPolicy{MaxAttempts: 2}
+
+	orig := req
+	origBody := req.RequestBody.String()
+
+	for i := 0; i < 5; i++ {
+		cb.Call(&req)
+	}
+
+	if req.TimeOut != orig.TimeOut || req.URL != orig.URL || req.Method != orig.Method ||
+		!reflect.DeepEqual(req.Headers, orig.Headers) || req.Retry != orig.Retry || req.RequestBody != orig.RequestBody {
+		t.Errorf("The request should not have been modified, wanted %+v got %+v", orig, req)
+	}
+	if body := req.RequestBody.String(); body != origBody {
+		t.Errorf("The request body should not have been consumed, wanted %q got %q", origBody, body)
+	}
+}
+
+func TestBackOff(t *testing.T) {
+	cb := NewCircuitBreaker(100, time.Minute)
+
+	ts := newTestService(http.StatusOK, false)
+	defer ts.Close()
+	ts.setLatency(150 * time.Millisecond)
+
+	req := &Request{
+		URL:           ts.URL,
+		Method:        http.MethodGet,
+		AllowedStatus: []int{http.StatusOK},
+		TimeOut:       50 * time.Millisecond,
+		BackOff: func(t time.Duration) time.Duration {
+			return t * 2
+		},
+	}
+
+	// 50ms & 100ms time out, 200ms gets the response
+	for i, wantErr := range []bool{true, true, false} {
+		if _, err := cb.Call(req); (err != nil) != wantErr {
+			t.Fatalf("Call:%d, wanted error:%v, got %v", i+1, wantErr, err)
+		}
+	}
+
+	// back to 50ms after the success
+	if _, err := cb.Call(req); err == nil {
+		t.Fatal("The timeout should have been reset after the success")
+	}
+}
+
+func TestBackOffTimeOutBound(t *testing.T) {
+	req := &Request{
+		TimeOut: time.Second,
+		BackOff: func(t time.Duration) time.Duration {
+			return t * 5
+		},
+	}
+
+	for failures, want := range map[int]time.Duration{0: time.Second, 1: 5 * time.Second, 2: 10 * time.Second,
+		1000: 10 * time.Second} {
+		if timeout := req.backOffTimeOut(failures); timeout != want {
+			t.Errorf("Failures:%d, invalid timeout, wanted %v got %v", failures, want, timeout)
+		}
+	}
+
+	req.MaxTimeOut = 30 * time.Second
+	if timeout := req.backOffTimeOut(3); timeout != 30*time.Second {
+		t.Errorf("Invalid timeout, wanted %v got %v", 30*time.Second, timeout)
+	}
+}
+
+func TestBackOffCountCapped(t *testing.T) {
+	calls := 0
+	req := &Request{
+		TimeOut:    time.Second,
+		MaxTimeOut: 4 * time.Second,
+		BackOff: func(t time.Duration) time.Duration {
+			calls++
+			return t * 2
+		},
+	}
+
+	cb := NewCircuitBreaker(5, time.Minute)
+	for i := 0; i < 100; i++ {
+		cb.updateBackOff(req, &noResponseError{errors.New("timeout")})
+	}
+	if cb.noResponseCount != 2 {
+		t.Errorf("The count should stop at the max timeout, wanted %d got %d", 2, cb.noResponseCount)
+	}
+
+	// not growing the timeout at all
+	req.BackOff = func(t time.Duration) time.Duration {
+		calls++
+		return t
+	}
+	calls = 0
+	if timeout := req.backOffTimeOut(1000); timeout != time.Second || calls != 1 {
+		t.Errorf("Expected %v after a single call of BackOff, got %v after %d calls", time.Second, timeout, calls)
+	}
+
+	cb.updateBackOff(req, errors.New("responded"))
+	if cb.noResponseCount != 0 {
+		t.Errorf("Expected the count to be reset, got %d", cb.noResponseCount)
+	}
+}
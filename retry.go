@@ -0,0 +1,225 @@
+package cutout
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Jitter strategies spreading the retries of many callers apart
+const (
+	NoJitter           = "NONE"
+	FullJitter         = "FULL"
+	DecorrelatedJitter = "DECORRELATED"
+)
+
+// RetryPolicy configures the retries of a request
+//
+// A request is attempted up to MaxAttempts times in total. The delay before each retry grows exponentially from
+// BaseDelay, up to MaxDelay if provided, randomized with the Jitter strategy. If the service responds with a
+// Retry-After header, the delay is at least as long as it asks for, unless that is longer than MaxDelay, in which case
+// the request is not retried.
+//
+// By default, the requests failing with the statuses 429, 502, 503 & 504 or with any other error, like a connection
+// error or a timeout, are retried. RetryableStatus and RetryableError override that.
+//
+// Every attempt goes through the circuit breaker and is recorded in its analytics, the retries stop as soon as the
+// circuit doesn't let an attempt through, the fallbacks serve the call then.
+//
+// Example:
+//
+//  req := cutout.Request{
+// 	 URL:     "http://localhost:9090",
+// 	 Method:  http.MethodGet,
+// 	 TimeOut: 2 * time.Second,
+// 	 Retry: &cutout.RetryPolicy{
+// 		 MaxAttempts: 3,
+// 		 BaseDelay:   100 * time.Millisecond,
+// 		 MaxDelay:    2 * time.Second,
+// 		 Jitter:      cutout.FullJitter,
+// 	 },
+//  }
+type RetryPolicy struct {
+	MaxAttempts     int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	Jitter          string
+	RetryableStatus func(status int) bool
+	RetryableError  func(err error) bool
+}
+
+// the statuses retried by default
+var retryableStatus = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+// whether a failed attempt should be retried
+func (p *RetryPolicy) retryable(err error) bool {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		if p.RetryableStatus != nil {
+			return p.RetryableStatus(statusErr.StatusCode)
+		}
+		return retryableStatus[statusErr.StatusCode]
+	}
+
+	if p.RetryableError != nil {
+		return p.RetryableError(err)
+	}
+	return true
+}
+
+// the delay before the given retry, prev is the delay before the previous one
+func (p *RetryPolicy) delay(retry int, prev time.Duration) time.Duration {
+	var d time.Duration
+
+	switch p.Jitter {
+	case DecorrelatedJitter:
+		if prev < p.BaseDelay {
+			prev = p.BaseDelay
+		}
+		d = p.BaseDelay + randDuration(3*prev-p.BaseDelay)
+	default:
+		d = p.BaseDelay << uint(retry-1)
+		if d < p.BaseDelay { // overflowed
+			d = p.MaxDelay
+		}
+	}
+
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+
+	if p.Jitter == FullJitter {
+		d = randDuration(d)
+	}
+
+	return d
+}
+
+// a random duration in [0, d]
+func randDuration(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// the delay asked for by the Retry-After header of the response, if any
+func retryAfter(resp *Response) (time.Duration, bool) {
+	if resp == nil || resp.Response == nil {
+		return 0, false
+	}
+
+	ra := resp.Header.Get("Retry-After")
+	if ra == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(ra); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(ra); err == nil {
+		return time.Until(t), true
+	}
+
+	return 0, false
+}
+
+// whether the circuit breaker itself turned the call down, rather than the call failing
+func isRejection(err error) bool {
+	return errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrTooManyHalfOpenRequests) ||
+		errors.Is(err, ErrAllFallbacksFailed) || errors.Is(err, ErrBulkheadFull) ||
+		errors.Is(err, ErrRateLimited) || errors.Is(err, ErrConcurrencyLimitExceeded)
+}
+
+// the timeout of the request, backed off by the consecutive failures to get a response
+func (c *CircuitBreaker) backOffTimeOut(req *Request) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return req.backOffTimeOut(c.noResponseCount)
+}
+
+// count the consecutive failures to get a response, any response resets the count. The count stops growing once it
+// no longer backs off the timeout of the request, e.g, the timeout has reached its max.
+func (c *CircuitBreaker) updateBackOff(req *Request, err error) {
+	var noResp *noResponseError
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if errors.As(err, &noResp) {
+		if req.backOffTimeOut(c.noResponseCount+1) > req.backOffTimeOut(c.noResponseCount) {
+			c.noResponseCount++
+		}
+	} else {
+		c.noResponseCount = 0
+	}
+}
+
+// the operation making the request with the backed off timeout
+func (c *CircuitBreaker) requestOperation(req *Request) func(context.Context) (*Response, error) {
+	return func(ctx context.Context) (*Response, error) {
+		resp, err := req.makeRequest(ctx, c.client(), c.backOffTimeOut(req))
+		if ctx.Err() == nil { // the caller giving up says nothing about the timeout
+			c.updateBackOff(req, err)
+		}
+		return resp, err
+	}
+}
+
+// make the request through the circuit, retrying it as the retry policy of the request says
+func (c *CircuitBreaker) callWithRetries(ctx context.Context, req *Request,
+	fallbackFuncs []func() (*Response, error)) (*Response, error) {
+	policy := req.Retry
+	var prevDelay time.Duration
+
+	info := callInfoFrom(ctx)
+	for attempt := 1; ; attempt++ {
+		if info != nil {
+			info.Attempts = attempt
+		}
+
+		var resp *Response
+		var err error
+		if req.hedged() {
+			resp, err = c.callHedged(ctx, req, attempt, fallbackFuncs)
+		} else {
+			resp, err = executeAttempt(ctx, c, c.requestOperation(req), describeHTTPAttempt(req.URL, req.Method, attempt),
+				fallbackFuncs)
+		}
+
+		if err == nil || policy == nil || attempt >= policy.MaxAttempts || isRejection(err) || ctx.Err() != nil ||
+			!policy.retryable(err) {
+			return resp, err
+		}
+
+		delay := policy.delay(attempt, prevDelay)
+		if ra, ok := retryAfter(resp); ok {
+			if policy.MaxDelay > 0 && ra > policy.MaxDelay { // longer than we are willing to wait
+				return resp, err
+			}
+			if ra > delay {
+				delay = ra
+			}
+		}
+		prevDelay = delay
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return resp, err
+		case <-timer.C:
+		}
+
+		c.mu.Lock()
+		c.addAnalyticsRetryCount()
+		c.mu.Unlock()
+	}
+}
@@ -0,0 +1,198 @@
+package cutout
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// a service responding with the given statuses in order, the last one repeats
+type scriptedService struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	bodies   []string
+	header   http.Header
+}
+
+func newScriptedService(statuses ...int) *scriptedService {
+	ss := &scriptedService{statuses: statuses, header: http.Header{}}
+	ss.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bb, _ := io.ReadAll(r.Body)
+
+		ss.mu.Lock()
+		status := ss.statuses[0]
+		if len(ss.statuses) > 1 {
+			ss.statuses = ss.statuses[1:]
+		}
+		ss.bodies = append(ss.bodies, string(bb))
+		for key, values := range ss.header {
+			w.Header()[key] = values
+		}
+		ss.mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	return ss
+}
+
+func (ss *scriptedService) hits() int {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return len(ss.bodies)
+}
+
+func TestRetryUntilSuccess(t *testing.T) {
+	cb := NewCircuitBreaker(5, time.Minute)
+	cb.InitAnalytics()
+
+	ss := newScriptedService(http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK)
+	defer ss.Close()
+
+	req := &Request{
+		URL:           ss.URL,
+		Method:        http.MethodPost,
+		RequestBody:   bytes.NewBuffer([]byte(`{"name":"abcd"}`)),
+		AllowedStatus: []int{http.StatusOK},
+		TimeOut:       time.Second,
+		Retry:         &RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond, Jitter: FullJitter},
+	}
+
+	resp, err := cb.Call(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("The request should have succeeded on the third attempt, got %v", err)
+	}
+	for i, body := range ss.bodies {
+		if body != `{"name":"abcd"}` {
+			t.Errorf("Attempt:%d, the request body should have been sent again, got %q", i+1, body)
+		}
+	}
+
+	anlcts := cb.GetAnalytics()
+	if anlcts.RequestSent != 3 || anlcts.Retries != 2 || anlcts.TotalFailures != 2 || anlcts.TotalCalls != 1 {
+		t.Errorf("Invalid analytics, request sent:%d, retries:%d, total failures:%d, total calls:%d",
+			anlcts.RequestSent, anlcts.Retries, anlcts.TotalFailures, anlcts.TotalCalls)
+	}
+	for i, rr := range anlcts.RequestRecords {
+		if rr.Attempt != i+1 {
+			t.Errorf("Invalid attempt in the request record, wanted %d got %d", i+1, rr.Attempt)
+		}
+	}
+}
+
+func TestRetryStopsWhenCircuitOpens(t *testing.T) {
+	cb := NewCircuitBreaker(2, time.Minute)
+
+	ss := newScriptedService(http.StatusServiceUnavailable)
+	defer ss.Close()
+
+	req := &Request{
+		URL:     ss.URL,
+		Method:  http.MethodGet,
+		TimeOut: time.Second,
+		Retry:   &RetryPolicy{MaxAttempts: 5},
+	}
+
+	resp, err := cb.Call(req, cacheFallback)
+	if err != nil || resp.BodyString != "cache" {
+		t.Fatalf("The fallback should have served the call once the circuit opened, got %v", err)
+	}
+	if hits := ss.hits(); hits != 2 {
+		t.Errorf("The retries should have stopped when the circuit opened, got %d requests", hits)
+	}
+}
+
+func TestRetryNonRetryable(t *testing.T) {
+	cb := NewCircuitBreaker(5, time.Minute)
+
+	ss := newScriptedService(http.StatusBadRequest)
+	defer ss.Close()
+
+	req := &Request{
+		URL:     ss.URL,
+		Method:  http.MethodGet,
+		TimeOut: time.Second,
+		Retry:   &RetryPolicy{MaxAttempts: 3},
+	}
+	cb.Call(req)
+	if hits := ss.hits(); hits != 1 {
+		t.Errorf("A bad request shouldn't have been retried, got %d requests", hits)
+	}
+
+	req.Retry.RetryableStatus = func(status int) bool { return status == http.StatusBadRequest }
+	cb.Call(req)
+	if hits := ss.hits(); hits != 4 {
+		t.Errorf("A bad request should have been retried, got %d requests", hits-1)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	cb := NewCircuitBreaker(5, time.Minute)
+
+	ss := newScriptedService(http.StatusTooManyRequests, http.StatusOK)
+	defer ss.Close()
+	ss.header.Set("Retry-After", "1")
+
+	req := &Request{
+		URL:     ss.URL,
+		Method:  http.MethodGet,
+		TimeOut: time.Second,
+		Retry:   &RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: 500 * time.Millisecond},
+	}
+	if _, err := cb.Call(req); err == nil {
+		t.Fatal("A Retry-After longer than the max delay shouldn't have been waited for")
+	}
+
+	ss = newScriptedService(http.StatusTooManyRequests, http.StatusOK)
+	defer ss.Close()
+	ss.header.Set("Retry-After", "1")
+
+	req.URL = ss.URL
+	req.Retry.MaxDelay = 2 * time.Second
+	start := time.Now()
+	if _, err := cb.Call(req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if took := time.Since(start); took < time.Second {
+		t.Errorf("The retry should have waited for the Retry-After, took %v", took)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	p := &RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for retry, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond,
+		4: 800 * time.Millisecond, 5: time.Second, 100: time.Second} {
+		if d := p.delay(retry, 0); d != want {
+			t.Errorf("Retry:%d, invalid delay, wanted %v got %v", retry, want, d)
+		}
+	}
+
+	p.Jitter = FullJitter
+	for i := 0; i < 100; i++ {
+		if d := p.delay(3, 0); d < 0 || d > 400*time.Millisecond {
+			t.Fatalf("Invalid full jitter delay %v", d)
+		}
+	}
+
+	p.Jitter = DecorrelatedJitter
+	prev := time.Duration(0)
+	for i := 0; i < 100; i++ {
+		d := p.delay(i+1, prev)
+		upper := 3 * prev
+		if upper < p.BaseDelay {
+			upper = 3 * p.BaseDelay
+		}
+		if upper > p.MaxDelay {
+			upper = p.MaxDelay
+		}
+		if d < p.BaseDelay || d > upper {
+			t.Fatalf("Invalid decorrelated jitter delay %v, previous %v", d, prev)
+		}
+		prev = d
+	}
+}
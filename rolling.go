@@ -0,0 +1,122 @@
+package cutout
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// DefaultRollingWindow is the window the rolling counts of the analytics cover, unless told otherwise
+const DefaultRollingWindow = time.Minute
+
+// RollingCounts holds the counts of the calls made within the last Window(in steps of one second), so that the
+// failures of long ago don't outweigh the current state of the service
+type RollingCounts struct {
+	Window      time.Duration `json:"window"`
+	Successes   int           `json:"successes"`
+	Failures    int           `json:"failures"`
+	Fallbacks   int           `json:"fallbacks"`
+	Rejections  int           `json:"rejections"`
+	Timeouts    int           `json:"timeouts"`
+	SuccessRate float64       `json:"success_rate"`
+	FailureRate float64       `json:"failure_rate"`
+}
+
+// WithRollingWindow makes the rolling counts cover the last d, DefaultRollingWindow if not provided
+func WithRollingWindow(d time.Duration) AnalyticsOption {
+	return func(a *Analytics) {
+		a.rollingWindow = d
+	}
+}
+
+// the counts & latencies of the calls made within the second it started on
+type rollingBucket struct {
+	RollingCounts
+	latency Histogram
+	second  int64
+}
+
+// rollingCounter keeps the counts of the calls in a ring of one bucket per second
+type rollingCounter struct {
+	window  time.Duration
+	buckets []rollingBucket
+}
+
+func newRollingCounter(window time.Duration) *rollingCounter {
+	if window <= 0 {
+		window = DefaultRollingWindow
+	}
+	n := int((window + time.Second - 1) / time.Second)
+	return &rollingCounter{
+		window:  time.Duration(n) * time.Second,
+		buckets: make([]rollingBucket, n),
+	}
+}
+
+// the bucket of the given time. A call recorded late, after its bucket was taken over by a later second, is older than
+// the window, so it gets a bucket of its own which is not kept.
+func (r *rollingCounter) bucket(now time.Time) *rollingBucket {
+	second := now.Unix()
+	b := &r.buckets[second%int64(len(r.buckets))]
+	if second < b.second {
+		return &rollingBucket{second: second}
+	}
+	if second > b.second { // the bucket is from a previous round of the ring
+		*b = rollingBucket{second: second}
+	}
+	return b
+}
+
+// whether the bucket is within the window ending at the given second
+func (r *rollingCounter) inWindow(b *rollingBucket, second int64) bool {
+	return second-b.second < int64(len(r.buckets))
+}
+
+// the counts of the calls made within the window
+func (r *rollingCounter) counts(now time.Time) RollingCounts {
+	counts := RollingCounts{Window: r.window}
+
+	second := now.Unix()
+	for i := range r.buckets {
+		b := &r.buckets[i]
+		if !r.inWindow(b, second) {
+			continue
+		}
+		counts.Successes += b.Successes
+		counts.Failures += b.Failures
+		counts.Fallbacks += b.Fallbacks
+		counts.Rejections += b.Rejections
+		counts.Timeouts += b.Timeouts
+	}
+
+	if calls := counts.Successes + counts.Failures; calls > 0 {
+		counts.SuccessRate = float64(counts.Successes) / float64(calls) * 100
+		counts.FailureRate = 100 - counts.SuccessRate
+	}
+
+	return counts
+}
+
+// the latencies of the calls made within the window
+func (r *rollingCounter) latency(now time.Time) *Histogram {
+	latency := &Histogram{}
+
+	second := now.Unix()
+	for i := range r.buckets {
+		if b := &r.buckets[i]; r.inWindow(b, second) {
+			latency.Merge(&b.latency)
+		}
+	}
+
+	return latency
+}
+
+// whether the error is a call timing out
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
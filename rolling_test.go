@@ -0,0 +1,111 @@
+package cutout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRollingCounter(t *testing.T) {
+	r := newRollingCounter(3 * time.Second)
+	now := time.Now()
+
+	r.bucket(now).Successes++
+	r.bucket(now).Failures++
+	r.bucket(now.Add(time.Second)).Successes += 2
+	r.bucket(now.Add(time.Second)).Timeouts++
+
+	counts := r.counts(now.Add(time.Second))
+	if counts.Successes != 3 || counts.Failures != 1 || counts.Timeouts != 1 {
+		t.Errorf("Invalid counts: %+v", counts)
+	}
+	if counts.SuccessRate != 75 || counts.FailureRate != 25 {
+		t.Errorf("Invalid rates, wanted: 75/25, got: %v/%v", counts.SuccessRate, counts.FailureRate)
+	}
+
+	counts = r.counts(now.Add(3 * time.Second)) // the first second is out of the window
+	if counts.Successes != 2 || counts.Failures != 0 {
+		t.Errorf("Invalid counts: %+v", counts)
+	}
+
+	r.bucket(now.Add(3*time.Second)).Rejections++ // takes over the bucket of the first second
+	counts = r.counts(now.Add(3 * time.Second))
+	if counts.Successes != 2 || counts.Rejections != 1 {
+		t.Errorf("Invalid counts: %+v", counts)
+	}
+	if counts.Window != 3*time.Second {
+		t.Errorf("Expected the window to be %v, got %v", 3*time.Second, counts.Window)
+	}
+
+	if counts := r.counts(now.Add(time.Minute)); counts != (RollingCounts{Window: 3 * time.Second}) {
+		t.Errorf("Expected no counts, got %+v", counts)
+	}
+}
+
+func TestRollingCounterLateWrite(t *testing.T) {
+	r := newRollingCounter(time.Second)
+	now := time.Now()
+
+	for i := 0; i < 5; i++ {
+		r.bucket(now).Successes++
+	}
+	r.bucket(now.Add(-time.Second)).Failures++ // a call of the previous second recorded late
+
+	counts := r.counts(now)
+	if counts.Successes != 5 || counts.Failures != 0 {
+		t.Errorf("The late write should neither wipe nor join the counts of the current second, got %+v", counts)
+	}
+}
+
+func TestIsTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	<-ctx.Done()
+
+	if !isTimeout(&noResponseError{fmt.Errorf("Get: %w", ctx.Err())}) {
+		t.Error("Expected a timeout")
+	}
+	if isTimeout(errors.New("connection refused")) {
+		t.Error("Expected no timeout")
+	}
+}
+
+func TestAnalyticsRollingCounts(t *testing.T) {
+	ts := newTestService(http.StatusOK, false)
+	defer ts.Close()
+
+	cb := NewCircuitBreaker(2, time.Minute)
+	cb.InitAnalytics(WithRollingWindow(10 * time.Second))
+
+	if _, err := cb.Call(ts.request()); err != nil {
+		t.Fatal(err)
+	}
+
+	ts.setLatency(100 * time.Millisecond)
+	req := ts.request()
+	req.TimeOut = 10 * time.Millisecond
+	cb.Call(req)
+	cb.Call(req) // trips the circuit
+
+	cb.Call(ts.request(), cacheFallback)
+	cb.Call(ts.request())
+
+	rolling := cb.GetAnalytics().Rolling
+	successRate := float64(1) / float64(3) * 100
+	want := RollingCounts{
+		Window:      10 * time.Second,
+		Successes:   1,
+		Failures:    2,
+		Fallbacks:   1,
+		Rejections:  2,
+		Timeouts:    2,
+		SuccessRate: successRate,
+		FailureRate: 100 - successRate,
+	}
+	if rolling != want {
+		t.Errorf("Invalid rolling counts, wanted: %+v, got: %+v", want, rolling)
+	}
+}
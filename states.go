@@ -11,12 +11,49 @@ const (
 	HalfOpenState = "HALF_OPEN"
 )
 
+// determine whether a new call is let through by the circuit, returns the generation of the circuit the call
+// belongs to or the reason the call is not let through. A hedged copy of a call is only let through while the circuit
+// is closed.
+func (c *CircuitBreaker) admit(hedge bool) (uint64, error) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	if err := c.check(hedge); err != nil {
+		return 0, err
+	}
+	if c.state == HalfOpenState {
+		c.halfOpenRequests++
+	}
+
+	return c.generation, nil
+}
+
+// determine whether the circuit would let a new call through in its current state, without letting it through yet
+func (c *CircuitBreaker) check(hedge bool) error {
+	c.setState()
+
+	if hedge && c.state != ClosedState {
+		return errHedgeSuppressed
+	}
+
+	switch c.state {
+	case OpenState:
+		return ErrCircuitOpen
+	case HalfOpenState:
+		if c.HalfOpenMaxRequests > 0 && c.halfOpenRequests >= c.HalfOpenMaxRequests {
+			return ErrTooManyHalfOpenRequests
+		}
+	}
+
+	return nil
+}
+
 // determine the current the state of the circuit
 func (c *CircuitBreaker) setState() {
 	prevState := c.state
 
-	if c.failCount >= c.FailThreshold {
-		if time.Now().Sub(*c.lastFailed) > c.HealthCheckPeriod { //the time for health check has arrived
+	if c.openedAt != nil {
+		if time.Now().Sub(*c.openedAt) > c.HealthCheckPeriod { //the time for health check has arrived
 			c.state = HalfOpenState
 		} else {
 			c.state = OpenState
@@ -26,29 +63,170 @@ func (c *CircuitBreaker) setState() {
 	}
 
 	if c.state != prevState {
-		c.fireEvent(StateChangeEvent)
+		c.stateChanged()
+	}
+
+}
+
+// start a new generation of the circuit as it has changed its state
+func (c *CircuitBreaker) stateChanged() {
+	c.generation++
+	c.halfOpenRequests = 0
+	c.halfOpenSuccesses = 0
+	c.fireEvent(StateChangeEvent)
+}
+
+// trip the circuit, it opens from the next call onwards
+func (c *CircuitBreaker) tripCircuit() {
+	now := time.Now()
+	c.openedAt = &now
+}
+
+// open the circuit again as a trial request has failed, the health check period starts over from the failure
+func (c *CircuitBreaker) reopenCircuit() {
+	c.tripCircuit()
+	c.state = OpenState
+	c.fireEvent(HalfOpenFailureEvent)
+	c.stateChanged()
+}
+
+// record the result of a request sent at the given time during the given generation of the circuit
+func (c *CircuitBreaker) recordResult(generation uint64, sentAt time.Time, err error) {
+	now := time.Now()
+	outcome := Outcome{
+		Err:        err,
+		Duration:   now.Sub(sentAt),
+		OccurredAt: now,
+	}
+
+	if err != nil {
+		c.updateAnalyticsFailure(err)
+	} else {
+		c.addAnalyticsSuccessCount()
+	}
+
+	if c.AdaptiveLimiter != nil {
+		c.AdaptiveLimiter.update(outcome)
 	}
 
+	if c.SlowCallThreshold > 0 && outcome.Duration > c.SlowCallThreshold {
+		outcome.Slow = true
+		c.fireEvent(SlowCallEvent)
+		c.addAnalyticsSlowCallCount()
+	}
+
+	if generation != c.generation { // the circuit has changed its state since the request was sent
+		return
+	}
+
+	if c.state == HalfOpenState {
+		c.halfOpenRequests--
+		if err != nil {
+			c.updateFailData()
+			c.reopenCircuit()
+			return
+		}
+		c.halfOpenSuccesses++
+		if c.halfOpenSuccesses >= c.halfOpenSuccessThreshold() { // convinced, close from the next call onwards
+			c.resetCircuit()
+		}
+		return
+	}
+
+	if err != nil {
+		c.updateFailData()
+	} else {
+		c.failCount = 0
+		c.lastFailed = nil
+	}
+
+	if c.openedAt != nil { // already tripped, opens on the next call
+		return
+	}
+
+	outcome.ConsecutiveFailures = c.failCount
+	if c.tripPolicy().Trip(outcome) {
+		c.tripCircuit()
+	}
+}
+
+// record a call sent during the given generation of the circuit, which was cancelled by the caller, or lost to a faster
+// copy of a hedged call, which the caller hasn't given up on
+func (c *CircuitBreaker) recordCancellation(generation uint64, lostHedge bool) {
+	if !lostHedge {
+		c.addAnalyticsCancelledCount()
+	}
+
+	if generation == c.generation && c.state == HalfOpenState {
+		c.halfOpenRequests-- // let another trial request through instead
+	}
+}
+
+// the policy deciding when the circuit opens
+func (c *CircuitBreaker) tripPolicy() TripPolicy {
+	if c.TripPolicy != nil {
+		return c.TripPolicy
+	}
+	return ConsecutiveFailures(c.FailThreshold)
+}
+
+// the number of consecutive successful trial requests needed to close the circuit from the half open state
+func (c *CircuitBreaker) halfOpenSuccessThreshold() int {
+	if c.HalfOpenSuccessThreshold > 0 {
+		return c.HalfOpenSuccessThreshold
+	}
+	return 1
 }
 
 // reset the circuit to its initial state
 func (c *CircuitBreaker) resetCircuit() {
 	c.failCount = 0
 	c.lastFailed = nil
+	c.openedAt = nil
+	c.tripPolicy().Reset()
+}
+
+// Snapshot holds the state of a circuit breaker at a point in time
+type Snapshot struct {
+	Name       string     `json:"name"`
+	State      string     `json:"state"`
+	FailCount  int        `json:"fail_count"`
+	LastFailed *time.Time `json:"last_failed"`
+	Analytics  *Analytics `json:"analytics,omitempty"`
+}
+
+// Snapshot returns the current state, fail count and analytics of the circuit breaker, all taken at once
+func (c *CircuitBreaker) Snapshot() Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return Snapshot{
+		Name:       c.Name,
+		State:      c.state,
+		FailCount:  c.failCount,
+		LastFailed: c.lastFailed,
+		Analytics:  c.analyticsSnapshot(),
+	}
 }
 
 // State returns the current satte of the circuit
 func (c *CircuitBreaker) State() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.state
 }
 
 // FailCount returns the count of failure
 func (c *CircuitBreaker) FailCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.failCount
 }
 
 // LastFailed returns the time object of the last failure
 func (c *CircuitBreaker) LastFailed() *time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.lastFailed
 }
 
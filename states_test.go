@@ -0,0 +1,294 @@
+package cutout
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testService struct {
+	*httptest.Server
+	status  int32
+	hits    int32
+	latency int64
+	blocked chan struct{}
+}
+
+// newTestService starts a service responding with the given status, if block is true every request waits
+// until release is called
+func newTestService(status int, block bool) *testService {
+	ts := &testService{status: int32(status)}
+	if block {
+		ts.blocked = make(chan struct{})
+	}
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&ts.hits, 1)
+		if ts.blocked != nil {
+			<-ts.blocked
+		}
+		time.Sleep(time.Duration(atomic.LoadInt64(&ts.latency)))
+		w.WriteHeader(int(atomic.LoadInt32(&ts.status)))
+	}))
+	return ts
+}
+
+func (ts *testService) setStatus(status int) {
+	atomic.StoreInt32(&ts.status, int32(status))
+}
+
+func (ts *testService) setLatency(latency time.Duration) {
+	atomic.StoreInt64(&ts.latency, int64(latency))
+}
+
+func (ts *testService) release() {
+	close(ts.blocked)
+}
+
+func (ts *testService) request() *Request {
+	return &Request{
+		URL:           ts.URL,
+		Method:        http.MethodGet,
+		AllowedStatus: []int{http.StatusOK},
+		TimeOut:       2 * time.Second,
+	}
+}
+
+func cacheFallback() (*Response, error) {
+	return &Response{BodyString: "cache"}, nil
+}
+
+func tripCircuit(t *testing.T, cb *CircuitBreaker) {
+	ts := newTestService(http.StatusInternalServerError, false)
+	defer ts.Close()
+
+	for i := 0; i < cb.FailThreshold; i++ {
+		cb.Call(ts.request(), cacheFallback)
+	}
+	if _, err := cb.Call(ts.request()); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected %v, got %v", ErrCircuitOpen, err)
+	}
+}
+
+func TestHalfOpenMaxRequests(t *testing.T) {
+	cb := NewCircuitBreaker(1, 50*time.Millisecond)
+	cb.HalfOpenMaxRequests = 2
+	cb.InitAnalytics()
+	tripCircuit(t, cb)
+
+	time.Sleep(2 * cb.HealthCheckPeriod)
+
+	ts := newTestService(http.StatusOK, true)
+	defer ts.Close()
+
+	var wg sync.WaitGroup
+	var fallbacks int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := cb.Call(ts.request(), cacheFallback)
+			if err != nil {
+				t.Error(err.Error())
+				return
+			}
+			if resp.BodyString == "cache" {
+				atomic.AddInt32(&fallbacks, 1)
+			}
+		}()
+	}
+
+	for atomic.LoadInt32(&ts.hits)+atomic.LoadInt32(&fallbacks) < 10 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	ts.release()
+	wg.Wait()
+
+	if hits := atomic.LoadInt32(&ts.hits); hits != 2 {
+		t.Errorf("Only %d trial requests should have reached the service, got %d", 2, hits)
+	}
+	if fallbacks != 8 {
+		t.Errorf("Invalid fallback calls, wanted:%d , got:%d", 8, fallbacks)
+	}
+	// one while open, tripping the circuit, the rest while half open
+	if rejections := cb.GetAnalytics().CircuitRejections; rejections != 9 {
+		t.Errorf("Invalid circuit rejections, wanted:%d , got:%d", 9, rejections)
+	}
+}
+
+func TestHalfOpenMaxRequestsWithoutFallbacks(t *testing.T) {
+	cb := NewCircuitBreaker(1, 50*time.Millisecond)
+	cb.HalfOpenMaxRequests = 1
+	tripCircuit(t, cb)
+
+	time.Sleep(2 * cb.HealthCheckPeriod)
+
+	ts := newTestService(http.StatusOK, true)
+	defer ts.Close()
+
+	errs := make(chan error)
+	go func() {
+		_, err := cb.Call(ts.request())
+		errs <- err
+	}()
+	for atomic.LoadInt32(&ts.hits) == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if _, err := cb.Call(ts.request()); !errors.Is(err, ErrTooManyHalfOpenRequests) {
+		t.Errorf("Expected %v, got %v", ErrTooManyHalfOpenRequests, err)
+	}
+
+	ts.release()
+	if err := <-errs; err != nil {
+		t.Errorf("The trial request should have succeeded, got %v", err)
+	}
+}
+
+func TestHalfOpenSuccessThreshold(t *testing.T) {
+	cb := NewCircuitBreaker(1, 50*time.Millisecond)
+	cb.HalfOpenSuccessThreshold = 3
+	tripCircuit(t, cb)
+
+	time.Sleep(2 * cb.HealthCheckPeriod)
+
+	ts := newTestService(http.StatusOK, false)
+	defer ts.Close()
+
+	for i := 0; i < cb.HalfOpenSuccessThreshold; i++ {
+		if _, err := cb.Call(ts.request()); err != nil {
+			t.Fatalf("Call:%d, unexpected error: %v", i+1, err)
+		}
+		if state := cb.State(); state != HalfOpenState {
+			t.Fatalf("Call:%d, incorrect state of the circuit received, wanted %s got %s", i+1, HalfOpenState, state)
+		}
+	}
+
+	cb.Call(ts.request())
+	if state := cb.State(); state != ClosedState {
+		t.Errorf("Incorrect state of the circuit received, wanted %s got %s", ClosedState, state)
+	}
+	if hits := atomic.LoadInt32(&ts.hits); hits != 4 {
+		t.Errorf("Invalid number of requests, wanted:%d, got:%d", 4, hits)
+	}
+}
+
+func TestHalfOpenSuccessThresholdResetOnFailure(t *testing.T) {
+	cb := NewCircuitBreaker(1, 50*time.Millisecond)
+	cb.HalfOpenSuccessThreshold = 2
+	tripCircuit(t, cb)
+
+	time.Sleep(2 * cb.HealthCheckPeriod)
+
+	ts := newTestService(http.StatusOK, false)
+	defer ts.Close()
+
+	cb.Call(ts.request())
+	ts.setStatus(http.StatusInternalServerError)
+	cb.Call(ts.request())
+
+	if _, err := cb.Call(ts.request()); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("A failed trial request should have opened the circuit, got %v", err)
+	}
+}
+
+func TestHalfOpenFailureReopensCircuit(t *testing.T) {
+	cb := NewCircuitBreaker(1, 100*time.Millisecond)
+
+	event := make(chan string, 20)
+	cb.InitEvent(event)
+
+	var states []string
+	checkState := func(want string) {
+		t.Helper()
+		state := cb.State()
+		if state != want {
+			t.Fatalf("Incorrect state of the circuit received, wanted %s got %s", want, state)
+		}
+		if states[len(states)-1] != state {
+			states = append(states, state)
+		}
+	}
+	probe := func(status int) {
+		t.Helper()
+		ts := newTestService(status, true)
+		defer ts.Close()
+
+		done := make(chan struct{})
+		go func() {
+			cb.Call(ts.request(), cacheFallback)
+			close(done)
+		}()
+		for atomic.LoadInt32(&ts.hits) == 0 {
+			time.Sleep(5 * time.Millisecond)
+		}
+		checkState(HalfOpenState)
+		ts.release()
+		<-done
+	}
+
+	ts := newTestService(http.StatusOK, false)
+	defer ts.Close()
+
+	cb.Call(ts.request())
+	states = append(states, cb.State())
+
+	ts.setStatus(http.StatusInternalServerError)
+	cb.Call(ts.request(), cacheFallback)
+	cb.Call(ts.request(), cacheFallback)
+	checkState(OpenState)
+
+	time.Sleep(cb.HealthCheckPeriod + 20*time.Millisecond)
+	probe(http.StatusInternalServerError)
+	checkState(OpenState) // reopened right away, not on the next call
+
+	reopenedAt := cb.LastFailed()
+	if time.Since(*reopenedAt) > 50*time.Millisecond {
+		t.Fatalf("The health check period should have restarted from the failed trial, last failed %v", reopenedAt)
+	}
+	time.Sleep(cb.HealthCheckPeriod / 2)
+	cb.Call(ts.request(), cacheFallback)
+	checkState(OpenState)
+
+	time.Sleep(cb.HealthCheckPeriod)
+	probe(http.StatusOK)
+	ts.setStatus(http.StatusOK)
+	cb.Call(ts.request())
+	checkState(ClosedState)
+
+	want := []string{ClosedState, OpenState, HalfOpenState, OpenState, HalfOpenState, ClosedState}
+	if len(states) != len(want) {
+		t.Fatalf("Invalid state sequence, wanted %v got %v", want, states)
+	}
+	for i := range want {
+		if states[i] != want[i] {
+			t.Fatalf("Invalid state sequence, wanted %v got %v", want, states)
+		}
+	}
+
+	close(event)
+	var events []string
+	for e := range event {
+		events = append(events, e)
+	}
+	// CLOSED, OPEN, HALF_OPEN, OPEN, HALF_OPEN, CLOSED
+	wantEvents := []string{
+		StateChangeEvent,
+		FailureEvent, StateChangeEvent,
+		StateChangeEvent,
+		FailureEvent, HalfOpenFailureEvent, StateChangeEvent,
+		StateChangeEvent,
+		StateChangeEvent,
+	}
+	if len(events) != len(wantEvents) {
+		t.Fatalf("Invalid event sequence, wanted %v got %v", wantEvents, events)
+	}
+	for i := range wantEvents {
+		if events[i] != wantEvents[i] {
+			t.Fatalf("Invalid event sequence, wanted %v got %v", wantEvents, events)
+		}
+	}
+}
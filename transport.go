@@ -0,0 +1,237 @@
+package cutout
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Transport is an http.RoundTripper making the requests through a circuit breaker, so that any existing http.Client
+// can be protected by plugging it in as the transport of the client.
+//
+// The responses are classified using AllowedStatus the same way as the Request does, a response with a status that is
+// not allowed counts as a failure but is still returned to the client as is. While the circuit doesn't let the requests
+// through, the Fallback builds the responses, if there is no Fallback the request fails with ErrCircuitOpen(or
+// ErrTooManyHalfOpenRequests).
+//
+// A request timing out, be it the Timeout of the client or the deadline of the request's context, counts as a failure
+// of the service, a request cancelled by the client doesn't count at all.
+//
+// Example:
+//
+//  client := &http.Client{
+// 	 Transport: cutout.NewTransport(cb, http.DefaultTransport, []int{http.StatusOK}),
+//  }
+//
+//  resp, err := client.Get("http://localhost:9090")
+//  if errors.Is(err, cutout.ErrCircuitOpen) {
+// 	 ...
+//  }
+type Transport struct {
+	Breaker       *CircuitBreaker
+	Base          http.RoundTripper
+	AllowedStatus []int
+	Fallback      func(*http.Request) (*http.Response, error)
+}
+
+// NewTransport creates a new transport making the requests through the given circuit breaker with the base transport,
+// the transport of the DefaultClient is used if base is nil
+func NewTransport(cb *CircuitBreaker, base http.RoundTripper, allowedStatus []int) *Transport {
+	return &Transport{
+		Breaker:       cb,
+		Base:          base,
+		AllowedStatus: allowedStatus,
+	}
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var fallbackFuncs []func() (*http.Response, error)
+	if t.Fallback != nil {
+		fallbackFuncs = append(fallbackFuncs, func() (*http.Response, error) {
+			return t.Fallback(req)
+		})
+	}
+
+	sent := false
+	resp, err := execute(context.WithValue(req.Context(), roundTripKey{}, true), t.Breaker, func(ctx context.Context) (*http.Response, error) {
+		sent = true
+		return t.roundTrip(req)
+	}, describeRoundTrip(req), fallbackFuncs)
+
+	if !sent && req.Body != nil { // a round tripper must always close the body
+		req.Body.Close()
+	}
+
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) { // a failure for the circuit, but a valid response for the client
+		return resp, nil
+	}
+
+	return resp, err
+}
+
+// marks the context of a round trip, its deadline is the timeout of the client rather than the caller giving up
+type roundTripKey struct{}
+
+func (t *Transport) roundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = DefaultClient.Transport
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if isFailureStatus(resp.StatusCode, t.AllowedStatus) {
+		return resp, newHTTPStatusError(&Response{Response: resp})
+	}
+
+	return resp, nil
+}
+
+// describes a round trip in its request record, the body is left for the client to read
+func describeRoundTrip(req *http.Request) func(*RequestRecord, *http.Response) {
+	return func(rr *RequestRecord, resp *http.Response) {
+		rr.Name = req.URL.String()
+		rr.Method = req.Method
+		if resp != nil {
+			rr.StatusCode = resp.StatusCode
+			rr.StatusText = resp.Status
+		}
+	}
+}
+
+// KeyedTransport is an http.RoundTripper making the requests through one circuit breaker per key, so that a single
+// failing service doesn't open the circuit for all the services called by the same http.Client.
+//
+// The key of a request is given by Key, KeyByHost if none is provided. The circuit breakers are fetched from the
+// Registry by their keys, so they are created lazily on the first request of each key, all sharing the same settings.
+// If there is no Registry, one is created on the first request, making the circuit breakers with NewBreaker(like the
+// ones of the DefaultRegistry if nil).
+//
+// Example:
+//
+//  tr := cutout.NewKeyedTransport(http.DefaultTransport, nil, cutout.KeyByHost, func(key string) *cutout.CircuitBreaker {
+// 	 return cutout.NewCircuitBreaker(10, 15*time.Second)
+//  })
+//  client := &http.Client{Transport: tr}
+//
+//  ...
+//
+//  for host, cb := range tr.Breakers() {
+// 	 log.Println(host, cb.State())
+//  }
+type KeyedTransport struct {
+	Base          http.RoundTripper
+	AllowedStatus []int
+	Fallback      func(*http.Request) (*http.Response, error)
+	Key           func(*http.Request) string
+	NewBreaker    func(key string) *CircuitBreaker
+	Registry      *Registry
+	mu            sync.Mutex
+}
+
+// NewKeyedTransport creates a new transport making the requests through one circuit breaker per key, the circuit
+// breakers are created with newBreaker(like the ones of the DefaultRegistry if nil)
+func NewKeyedTransport(base http.RoundTripper, allowedStatus []int, key func(*http.Request) string,
+	newBreaker func(key string) *CircuitBreaker) *KeyedTransport {
+	return &KeyedTransport{
+		Base:          base,
+		AllowedStatus: allowedStatus,
+		Key:           key,
+		NewBreaker:    newBreaker,
+		Registry:      NewRegistry(newBreaker),
+	}
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *KeyedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := t.Key
+	if key == nil {
+		key = KeyByHost
+	}
+
+	tr := &Transport{
+		Breaker:       t.Breaker(key(req)),
+		Base:          t.Base,
+		AllowedStatus: t.AllowedStatus,
+		Fallback:      t.Fallback,
+	}
+
+	return tr.RoundTrip(req)
+}
+
+// Breaker returns the circuit breaker of the given key, creating it if there is none yet
+func (t *KeyedTransport) Breaker(key string) *CircuitBreaker {
+	return t.registry().Get(key)
+}
+
+// Breakers returns the circuit breakers created so far by their keys
+func (t *KeyedTransport) Breakers() map[string]*CircuitBreaker {
+	breakers := make(map[string]*CircuitBreaker)
+	for _, cb := range t.registry().List() {
+		breakers[cb.Name] = cb
+	}
+	return breakers
+}
+
+// the registry of the circuit breakers, created on first use if none is provided
+func (t *KeyedTransport) registry() *Registry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.Registry == nil {
+		t.Registry = NewRegistry(t.NewBreaker)
+	}
+	return t.Registry
+}
+
+// KeyByHost keys the requests by the host(and port) they are sent to
+func KeyByHost(req *http.Request) string {
+	return req.URL.Host
+}
+
+// KeyByPathTemplate returns a key function keying the requests by their host along with the first path template
+// matching their path, a template segment in braces matches any segment(e.g, "/users/{id}" matches "/users/42").
+// Requests matching no template are keyed by their host only.
+//
+// Example:
+//
+//  key := cutout.KeyByPathTemplate("/users/{id}", "/users/{id}/orders")
+//  key(req) // "api.example.com/users/{id}" for http://api.example.com/users/42
+func KeyByPathTemplate(templates ...string) func(*http.Request) string {
+	split := make([][]string, len(templates))
+	for i, tmpl := range templates {
+		split[i] = strings.Split(strings.Trim(tmpl, "/"), "/")
+	}
+
+	return func(req *http.Request) string {
+		segments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+		for i, tmpl := range split {
+			if matchPathTemplate(tmpl, segments) {
+				return req.URL.Host + templates[i]
+			}
+		}
+		return req.URL.Host
+	}
+}
+
+func matchPathTemplate(tmpl, segments []string) bool {
+	if len(tmpl) != len(segments) {
+		return false
+	}
+	for i, seg := range tmpl {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			continue
+		}
+		if seg != segments[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,246 @@
+package cutout
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTransport(t *testing.T) {
+	cb := NewCircuitBreaker(2, time.Minute)
+	cb.InitAnalytics()
+	event := make(chan string, 10)
+	cb.InitEvent(event)
+
+	ts := newTestService(http.StatusOK, false)
+	defer ts.Close()
+
+	client := &http.Client{Transport: NewTransport(cb, nil, []int{http.StatusOK})}
+
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	ts.setStatus(http.StatusInternalServerError)
+	for i := 0; i < cb.FailThreshold; i++ {
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatalf("A response with a status not allowed should still reach the client, got %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusInternalServerError {
+			t.Errorf("Incorrent status code received, wanted %d got %d", http.StatusInternalServerError, resp.StatusCode)
+		}
+	}
+
+	if _, err := client.Get(ts.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected %v, got %v", ErrCircuitOpen, err)
+	}
+	if hits := atomic.LoadInt32(&ts.hits); hits != 3 {
+		t.Errorf("Invalid number of requests, wanted:%d, got:%d", 3, hits)
+	}
+
+	anlcts := cb.GetAnalytics()
+	if anlcts.RequestSent != 3 || anlcts.TotalFailures != 2 {
+		t.Errorf("Invalid analytics, wanted 3 requests sent & 2 failures, got %d requests sent & %d failures",
+			anlcts.RequestSent, anlcts.TotalFailures)
+	}
+	if rr := anlcts.RequestRecords[1]; rr.StatusCode != http.StatusInternalServerError || rr.Method != http.MethodGet {
+		t.Errorf("Invalid request record, got %+v", rr)
+	}
+}
+
+func TestTransportClientTimeout(t *testing.T) {
+	cb := NewCircuitBreaker(2, time.Minute)
+	cb.InitAnalytics()
+
+	ts := newTestService(http.StatusOK, false)
+	defer ts.Close()
+	ts.setLatency(200 * time.Millisecond)
+
+	client := &http.Client{Timeout: 50 * time.Millisecond, Transport: NewTransport(cb, nil, nil)}
+
+	for i := 0; i < cb.FailThreshold; i++ {
+		if _, err := client.Get(ts.URL); err == nil {
+			t.Fatal("The request should have timed out")
+		}
+	}
+	if _, err := client.Get(ts.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("The timeouts should have opened the circuit, expected %v, got %v", ErrCircuitOpen, err)
+	}
+
+	a := cb.GetAnalytics()
+	if a.TotalFailures != 2 || a.CancelledCalls != 0 {
+		t.Errorf("Expected %d failures & %d cancelled calls, got %d & %d", 2, 0, a.TotalFailures, a.CancelledCalls)
+	}
+}
+
+func TestTransportCancelled(t *testing.T) {
+	cb := NewCircuitBreaker(1, time.Minute)
+	cb.InitAnalytics()
+
+	ts := newTestService(http.StatusOK, false)
+	defer ts.Close()
+	ts.setLatency(200 * time.Millisecond)
+
+	client := &http.Client{Transport: NewTransport(cb, nil, nil)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	if _, err := client.Do(req); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected %v, got %v", context.Canceled, err)
+	}
+
+	a := cb.GetAnalytics()
+	if a.TotalFailures != 0 || a.CancelledCalls != 1 || cb.State() != ClosedState {
+		t.Errorf("The cancelled request should not count as a failure, got %d failures, %d cancelled calls, %s",
+			a.TotalFailures, a.CancelledCalls, cb.State())
+	}
+}
+
+func TestTransportFallback(t *testing.T) {
+	cb := NewCircuitBreaker(1, time.Minute)
+	tripCircuit(t, cb)
+
+	tr := NewTransport(cb, nil, nil)
+	tr.Fallback = func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"message":"cached"}`)),
+			Request:    req,
+		}, nil
+	}
+	client := &http.Client{Transport: tr}
+
+	body := &closeRecorder{Reader: strings.NewReader(`{}`)}
+	req, _ := http.NewRequest(http.MethodPost, "http://cutout.hehe", body)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("The fallback should have served the request, got %v", err)
+	}
+	defer resp.Body.Close()
+
+	bb, _ := io.ReadAll(resp.Body)
+	if string(bb) != `{"message":"cached"}` {
+		t.Errorf("Invalid fallback body, got %s", bb)
+	}
+	if !body.closed {
+		t.Error("The request body should have been closed")
+	}
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (cr *closeRecorder) Close() error {
+	cr.closed = true
+	return nil
+}
+
+func TestKeyedTransport(t *testing.T) {
+	healthy := newTestService(http.StatusOK, false)
+	defer healthy.Close()
+	dead := newTestService(http.StatusInternalServerError, false)
+	defer dead.Close()
+
+	created := 0
+	tr := NewKeyedTransport(nil, []int{http.StatusOK}, nil, func(key string) *CircuitBreaker {
+		created++
+		return NewCircuitBreaker(1, time.Minute)
+	})
+	client := &http.Client{Transport: tr}
+
+	for i := 0; i < 3; i++ {
+		if resp, err := client.Get(dead.URL); err == nil {
+			resp.Body.Close()
+		}
+		resp, err := client.Get(healthy.URL)
+		if err != nil {
+			t.Fatalf("The healthy service shouldn't be affected by the dead one, got %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	breakers := tr.Breakers()
+	if len(breakers) != 2 || created != 2 {
+		t.Fatalf("There should have been a circuit breaker per host, got %d", len(breakers))
+	}
+	if state := breakers[KeyByHost(newGetRequest(dead.URL))].State(); state != OpenState {
+		t.Errorf("Incorrect state of the circuit received, wanted %s got %s", OpenState, state)
+	}
+	if state := tr.Breaker(KeyByHost(newGetRequest(healthy.URL))).State(); state != ClosedState {
+		t.Errorf("Incorrect state of the circuit received, wanted %s got %s", ClosedState, state)
+	}
+}
+
+func TestKeyedTransportLiteral(t *testing.T) {
+	ts := newTestService(http.StatusOK, false)
+	defer ts.Close()
+
+	var created int32
+	tr := &KeyedTransport{NewBreaker: func(key string) *CircuitBreaker {
+		atomic.AddInt32(&created, 1)
+		return NewCircuitBreaker(1, time.Minute)
+	}}
+	client := &http.Client{Transport: tr}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(ts.URL)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	if breakers := tr.Breakers(); len(breakers) != 1 || atomic.LoadInt32(&created) == 0 {
+		t.Errorf("Expected a circuit breaker made with NewBreaker, got %d breakers", len(breakers))
+	}
+
+	tr = &KeyedTransport{} // without NewBreaker either
+	if resp, err := (&http.Client{Transport: tr}).Get(ts.URL); err != nil {
+		t.Error(err)
+	} else {
+		resp.Body.Close()
+	}
+	if cb := tr.Breaker(KeyByHost(newGetRequest(ts.URL))); cb.FailThreshold != 5 {
+		t.Errorf("Expected a default circuit breaker, got a fail threshold of %d", cb.FailThreshold)
+	}
+}
+
+func TestKeyByPathTemplate(t *testing.T) {
+	key := KeyByPathTemplate("/users/{id}", "/users/{id}/orders")
+
+	cases := map[string]string{
+		"http://api.example.com/users/42":        "api.example.com/users/{id}",
+		"http://api.example.com/users/42/orders": "api.example.com/users/{id}/orders",
+		"http://api.example.com/products/7":      "api.example.com",
+	}
+	for url, want := range cases {
+		if got := key(newGetRequest(url)); got != want {
+			t.Errorf("Invalid key for %s, wanted %s got %s", url, want, got)
+		}
+	}
+}
+
+func newGetRequest(url string) *http.Request {
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	return req
+}
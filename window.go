@@ -0,0 +1,119 @@
+package cutout
+
+import "time"
+
+// SlidingWindow configures the rate based tripping of the circuit
+//
+// The window either covers the last Size calls or, if Duration is provided, the calls made within the last
+// Duration(in steps of one second). The circuit opens when at least MinCalls calls are in the window and
+// FailureRate percent or more of them have failed(see FailureRate), or SlowCallRate percent or more of them were
+// slow(see SlowCallRate).
+type SlidingWindow struct {
+	Size         int
+	Duration     time.Duration
+	MinCalls     int
+	FailureRate  float64
+	SlowCallRate float64
+}
+
+// the outcome of a single call in the window
+type windowOutcome struct {
+	failed bool
+	slow   bool
+}
+
+// the outcome counts of a number of calls
+type windowCounts struct {
+	calls     int
+	failures  int
+	slowCalls int
+}
+
+func (wc *windowCounts) add(o windowOutcome) {
+	wc.calls++
+	if o.failed {
+		wc.failures++
+	}
+	if o.slow {
+		wc.slowCalls++
+	}
+}
+
+// a time based window bucket, holding the counts of the calls made within the second it started on
+type windowBucket struct {
+	windowCounts
+	second int64
+}
+
+// slidingWindow keeps the outcomes of the calls in a count based or time based window
+type slidingWindow struct {
+	outcomes []windowOutcome // count based, ring of the last outcomes
+	next     int
+	full     bool
+	buckets  []windowBucket // time based, ring of one bucket per second
+}
+
+func newSlidingWindow(size int, duration time.Duration) *slidingWindow {
+	if duration > 0 {
+		n := int((duration + time.Second - 1) / time.Second)
+		return &slidingWindow{buckets: make([]windowBucket, n)}
+	}
+
+	if size < 1 {
+		size = 1
+	}
+	return &slidingWindow{outcomes: make([]windowOutcome, size)}
+}
+
+func (w *slidingWindow) record(o windowOutcome) {
+	if w.buckets != nil {
+		now := time.Now().Unix()
+		b := &w.buckets[now%int64(len(w.buckets))]
+		if b.second != now { // the bucket is from a previous round of the ring
+			*b = windowBucket{second: now}
+		}
+		b.add(o)
+		return
+	}
+
+	w.outcomes[w.next] = o
+	w.next = (w.next + 1) % len(w.outcomes)
+	if w.next == 0 {
+		w.full = true
+	}
+}
+
+// the outcome counts of the calls currently in the window
+func (w *slidingWindow) counts() windowCounts {
+	var wc windowCounts
+
+	if w.buckets != nil {
+		oldest := time.Now().Unix() - int64(len(w.buckets)) + 1
+		for _, b := range w.buckets {
+			if b.second >= oldest {
+				wc.calls += b.calls
+				wc.failures += b.failures
+				wc.slowCalls += b.slowCalls
+			}
+		}
+		return wc
+	}
+
+	n := w.next
+	if w.full {
+		n = len(w.outcomes)
+	}
+	for _, o := range w.outcomes[:n] {
+		wc.add(o)
+	}
+	return wc
+}
+
+// whether count out of the calls in the window has reached the given percentage, given that there are at least
+// minCalls calls in the window
+func (wc windowCounts) reached(count, minCalls int, rate float64) bool {
+	if wc.calls == 0 || wc.calls < minCalls {
+		return false
+	}
+	return float64(count)/float64(wc.calls)*100 >= rate
+}
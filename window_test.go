@@ -0,0 +1,81 @@
+package cutout
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSlidingWindowCountBased(t *testing.T) {
+	w := newSlidingWindow(4, 0)
+
+	for _, failed := range []bool{true, true, false, false, false, true} {
+		w.record(windowOutcome{failed: failed})
+	}
+
+	wc := w.counts()
+	if wc.calls != 4 || wc.failures != 1 {
+		t.Errorf("Invalid window counts, wanted 4 calls & 1 failure, got %d calls & %d failures", wc.calls, wc.failures)
+	}
+	if wc.reached(wc.failures, 4, 50) {
+		t.Error("The window shouldn't have tripped with a 25% failure rate")
+	}
+	if !wc.reached(wc.failures, 4, 25) {
+		t.Error("The window should have tripped with a 25% failure rate")
+	}
+	if wc.reached(wc.failures, 5, 25) {
+		t.Error("The window shouldn't have tripped with less than the minimum calls")
+	}
+}
+
+func TestSlidingWindowTimeBased(t *testing.T) {
+	w := newSlidingWindow(0, time.Second)
+
+	w.record(windowOutcome{failed: true})
+	w.record(windowOutcome{slow: true})
+	if wc := w.counts(); wc.calls != 2 || wc.failures != 1 || wc.slowCalls != 1 {
+		t.Errorf("Invalid window counts, wanted 2 calls, 1 failure & 1 slow call, got %d calls, %d failures & %d slow calls",
+			wc.calls, wc.failures, wc.slowCalls)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+
+	if wc := w.counts(); wc.calls != 0 {
+		t.Errorf("The calls should have left the window, got %d calls", wc.calls)
+	}
+}
+
+func TestCircuitBreakerWithWindow(t *testing.T) {
+	cb := NewCircuitBreakerWithWindow(SlidingWindow{
+		Size:        20,
+		MinCalls:    10,
+		FailureRate: 50,
+	}, time.Minute)
+
+	ts := newTestService(http.StatusOK, false)
+	defer ts.Close()
+
+	// every other call fails, which never trips on consecutive failures
+	for i := 0; i < 9; i++ {
+		if i%2 == 0 {
+			ts.setStatus(http.StatusInternalServerError)
+		} else {
+			ts.setStatus(http.StatusOK)
+		}
+		cb.Call(ts.request())
+		if state := cb.State(); state != ClosedState {
+			t.Fatalf("Call:%d, incorrect state of the circuit received, wanted %s got %s", i+1, ClosedState, state)
+		}
+	}
+
+	ts.setStatus(http.StatusInternalServerError)
+	cb.Call(ts.request()) // the minimum calls have been made, 6 of 10 failed
+
+	if _, err := cb.Call(ts.request()); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected %v, got %v", ErrCircuitOpen, err)
+	}
+	if state := cb.State(); state != OpenState {
+		t.Errorf("Incorrect state of the circuit received, wanted %s got %s", OpenState, state)
+	}
+}